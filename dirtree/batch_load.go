@@ -0,0 +1,124 @@
+package dirtree
+
+import (
+	"context"
+)
+
+//RetrieveNextDepthFilesBatchFunc 批量查找多个folder的下一层子文件(夹),返回值按父目录id分组
+type RetrieveNextDepthFilesBatchFunc func(ctx context.Context, volumeId int64, folderIds []int64) (files, folders map[int64][]*File, err error)
+
+/*
+	BatchLoad
+	按层批量加载,每一层的folder每batchSize个调用一次retrieveBatch(batchSize<=0时使用默认值500),
+	所以加载整棵树的查询次数和深度成正比,而不是和folder数量成正比。
+	maxDepth,numLimit,sizeLimit以及preorderFunc,postorderFunc的含义跟BFSLoad一样,
+	注意:WithPageRetrieve对BatchLoad不生效。
+*/
+func (d *Dir) BatchLoad(ctx context.Context, batchSize int,
+	maxDepth, numLimit, sizeLimit int64,
+	retrieveBatch RetrieveNextDepthFilesBatchFunc,
+	preorderFunc, postorderFunc DirFunc, opts ...LoadOption) (totalSize, totalCount int64, err error) {
+	if batchSize <= 0 {
+		batchSize = defBatchSize
+	}
+	dfsInfo := d.newDfsLoadInfo(maxDepth, numLimit, sizeLimit, opts)
+	ctx, cancel := dfsInfo.opts.context(ctx)
+	defer cancel()
+	dfsInfo.start(ctx, d, "batch")
+
+	var levelDirs [][]*Dir //每一层的Dir,用于postorderFunc
+	currDepthDirs := []*Dir{d}
+	for len(currDepthDirs) > 0 {
+		var visitedDirs, unloadedDirs []*Dir
+		for _, dir := range currDepthDirs {
+			if err = dir.beforeLoad(ctx, dfsInfo, preorderFunc); err != nil {
+				if err == errSkipSubtree {
+					continue
+				}
+				return dfsInfo.finish(ctx, d, err)
+			}
+			visitedDirs = append(visitedDirs, dir)
+			if !dir.loaded {
+				unloadedDirs = append(unloadedDirs, dir)
+			}
+		}
+
+		var retrieved map[*Dir]batchResult
+		if retrieved, err = dfsInfo.retrieveBatch(ctx, unloadedDirs, batchSize, retrieveBatch); err != nil {
+			return dfsInfo.finish(ctx, d, err)
+		}
+
+		var nextDepthDirs []*Dir
+		for _, dir := range visitedDirs {
+			//逐个填充并检查限制,触发限制之后剩下的dir保持未加载,和BFSLoad一致
+			if result, ok := retrieved[dir]; ok {
+				if err = dir.fillNoRecurse(ctx, result.files, result.folders); err != nil {
+					return dfsInfo.finish(ctx, d, err)
+				}
+			}
+			if err = dfsInfo.addAndCheck(ctx, dir); err != nil {
+				return dfsInfo.finish(ctx, d, err)
+			}
+			nextDepthDirs = append(nextDepthDirs, dir.subDirs...)
+		}
+		levelDirs = append(levelDirs, visitedDirs)
+		currDepthDirs = nextDepthDirs
+	}
+
+	err = postorderByLevel(ctx, levelDirs, postorderFunc)
+	return dfsInfo.finish(ctx, d, err)
+}
+
+//batchResult 批量拉取到的一个dir的下一层数据
+type batchResult struct {
+	files, folders []*File
+}
+
+//retrieveBatch 按volume分组,每batchSize个folder批量拉取一次,返回每个dir的数据,由调用方填充
+func (info *dsfLoadInfo) retrieveBatch(ctx context.Context, dirs []*Dir,
+	batchSize int, retrieveBatch RetrieveNextDepthFilesBatchFunc) (map[*Dir]batchResult, error) {
+	if len(dirs) == 0 {
+		return nil, nil
+	}
+	if retrieveBatch == nil {
+		return nil, newDirError("load", dirs[0], ErrNoRetrieveNextDepthFilesFunc)
+	}
+
+	var volumeIds []int64 //保持volume出现的顺序
+	volumeDirs := make(map[int64][]*Dir)
+	for _, dir := range dirs {
+		volumeId := dir.originInfo.VolumeId
+		if _, ok := volumeDirs[volumeId]; !ok {
+			volumeIds = append(volumeIds, volumeId)
+		}
+		volumeDirs[volumeId] = append(volumeDirs[volumeId], dir)
+	}
+
+	retrieved := make(map[*Dir]batchResult, len(dirs))
+	for _, volumeId := range volumeIds {
+		dirs := volumeDirs[volumeId]
+		for start := 0; start < len(dirs); start += batchSize {
+			if err := ctxErr(ctx); err != nil {
+				return nil, err
+			}
+			end := start + batchSize
+			if end > len(dirs) {
+				end = len(dirs)
+			}
+			batchDirs := dirs[start:end]
+			folderIds := make([]int64, 0, len(batchDirs))
+			for _, dir := range batchDirs {
+				folderIds = append(folderIds, dir.originInfo.Id)
+			}
+			files, folders, err := retrieveBatch(ctx, volumeId, folderIds)
+			if err != nil {
+				info.logRetrieveFailed(ctx, batchDirs[0], err)
+				return nil, newDirError("retrieve", batchDirs[0], err)
+			}
+			for _, dir := range batchDirs {
+				retrieved[dir] = batchResult{files: files[dir.originInfo.Id], folders: folders[dir.originInfo.Id]}
+			}
+		}
+	}
+	return retrieved, nil
+}
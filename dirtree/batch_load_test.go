@@ -0,0 +1,120 @@
+package dirtree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+//newBatchMock 用getSubFilesMock模拟批量查询,calls记录每次查询的folderIds
+func newBatchMock(calls *[][]int64) RetrieveNextDepthFilesBatchFunc {
+	return func(ctx context.Context, volumeId int64, folderIds []int64) (files, folders map[int64][]*File, err error) {
+		*calls = append(*calls, folderIds)
+		files = make(map[int64][]*File)
+		folders = make(map[int64][]*File)
+		for _, folderId := range folderIds {
+			files[folderId], folders[folderId], _ = getSubFilesMock(ctx, volumeId, folderId)
+		}
+		return files, folders, nil
+	}
+}
+
+func TestBatchLoad(t *testing.T) {
+	Convey("TestBatchLoad", t, func() {
+		Convey("TestBatchLoad one call per depth", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			var calls [][]int64
+			totalSize, totalCount, err := dir.BatchLoad(nil, -1, -1, -1, -1, newBatchMock(&calls), nil, nil)
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 19)
+			So(totalSize, ShouldEqual, 10)
+			So(calls, ShouldResemble, [][]int64{{0}, {12, 13}, {22, 23, 24}, {33, 35, 36, 37}})
+			So(len(dir.GetAllFoldersAndFiles(nil)), ShouldEqual, 19)
+		})
+
+		Convey("TestBatchLoad batchSize", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			var calls [][]int64
+			_, totalCount, err := dir.BatchLoad(nil, 2, -1, -1, -1, newBatchMock(&calls), nil, nil)
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 19)
+			So(len(calls), ShouldEqual, 6)
+		})
+
+		Convey("TestBatchLoad pre and post order", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			var calls [][]int64
+			var preIds, postIds []int64
+			preorder := func(ctx context.Context, dir *Dir) error {
+				preIds = append(preIds, dir.GetId())
+				return nil
+			}
+			postorder := func(ctx context.Context, dir *Dir) error {
+				postIds = append(postIds, dir.GetId())
+				return nil
+			}
+			_, _, err := dir.BatchLoad(nil, -1, -1, -1, -1, newBatchMock(&calls), preorder, postorder)
+			So(err, ShouldBeNil)
+			So(preIds, ShouldResemble, []int64{0, 12, 13, 22, 23, 24, 33, 35, 36, 37})
+			So(postIds, ShouldResemble, []int64{33, 35, 36, 37, 22, 23, 24, 12, 13, 0})
+		})
+
+		Convey("TestBatchLoad limits", func() {
+			buildTreeForTest()
+			var calls [][]int64
+			_, _, err := newNewVirtualDirForTest().BatchLoad(nil, -1, -1, 12, -1, newBatchMock(&calls), nil, nil)
+			So(errors.Is(err, ErrFileNumLimit), ShouldBeTrue)
+			_, _, err = newNewVirtualDirForTest().BatchLoad(nil, -1, 2, -1, -1, newBatchMock(&calls), nil, nil)
+			So(errors.Is(err, ErrMaxPathDepthLimit), ShouldBeTrue)
+
+			report := &LoadReport{}
+			_, totalCount, err := newNewVirtualDirForTest().BatchLoad(nil, -1, 3, -1, -1, newBatchMock(&calls), nil, nil,
+				WithPartialResult(report))
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 17)
+			So(len(report.Hits), ShouldEqual, 4)
+		})
+
+		Convey("TestBatchLoad partial count matches the tree", func() {
+			for _, numLimit := range []int64{5, 7, 12} {
+				numLimit := numLimit
+				Convey(fmt.Sprintf("TestBatchLoad partial numLimit=%d", numLimit), func() {
+					buildTreeForTest()
+					var calls [][]int64
+					dir := newNewVirtualDirForTest()
+					totalSize, totalCount, err := dir.BatchLoad(nil, -1, -1, numLimit, -1, newBatchMock(&calls), nil, nil,
+						WithPartialResult(nil))
+					So(err, ShouldBeNil)
+					So(totalCount, ShouldEqual, len(dir.GetAllFoldersAndFiles(nil)))
+					size, count, err := dir.GetTotalSizeAndCount(nil)
+					So(err, ShouldBeNil)
+					So(totalCount, ShouldEqual, count)
+					So(totalSize, ShouldEqual, size)
+
+					bfsDir := newNewVirtualDirForTest()
+					bfsSize, bfsCount, err := bfsDir.BFSLoad(nil, -1, numLimit, -1, getSubFilesMock, nil, nil, WithPartialResult(nil))
+					So(err, ShouldBeNil)
+					So(totalCount, ShouldEqual, bfsCount)
+					So(totalSize, ShouldEqual, bfsSize)
+					So(fileIds(dir.GetAllFoldersAndFiles(nil)), ShouldResemble, fileIds(bfsDir.GetAllFoldersAndFiles(nil)))
+				})
+			}
+		})
+
+		Convey("TestBatchLoad retrieve error", func() {
+			buildTreeForTest()
+			errBatch := errors.New("batch failed")
+			failBatch := func(ctx context.Context, volumeId int64, folderIds []int64) (files, folders map[int64][]*File, err error) {
+				return nil, nil, errBatch
+			}
+			_, _, err := newNewVirtualDirForTest().BatchLoad(nil, -1, -1, -1, -1, failBatch, nil, nil)
+			So(errors.Is(err, errBatch), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,58 @@
+package dirtree
+
+import (
+	"context"
+)
+
+/*
+	BFSLoad
+	按层加载,参数和限制的含义跟DFSLoad一样。
+	和DFSLoad不同的是:numLimit等限制触发时,已经加载完的浅层一定是完整的,
+	不会出现一个很深的分支已经全部加载而它的兄弟目录还是空的情况。
+	preorderFunc在加载每个dir之前按BFS顺序调用,
+	postorderFunc在所有层都加载完之后从最深一层往上逐层调用,保证子节点先于父节点。
+*/
+func (d *Dir) BFSLoad(ctx context.Context,
+	maxDepth, numLimit, sizeLimit int64,
+	retrieveNextDepthFiles RetrieveNextDepthFilesFunc,
+	preorderFunc, postorderFunc DirFunc, opts ...LoadOption) (totalSize, totalCount int64, err error) {
+	dfsInfo := d.newDfsLoadInfo(maxDepth, numLimit, sizeLimit, opts)
+	ctx, cancel := dfsInfo.opts.context(ctx)
+	defer cancel()
+	dfsInfo.start(ctx, d, "bfs")
+	var levelDirs [][]*Dir //每一层的Dir,用于postorderFunc
+	err = d.bfsLevels(func(dir *Dir, level int) error {
+		if err := dir.loadNode(ctx, dfsInfo, retrieveNextDepthFiles, preorderFunc); err != nil {
+			return err
+		}
+		if postorderFunc != nil {
+			if len(levelDirs) <= level {
+				levelDirs = append(levelDirs, nil)
+			}
+			levelDirs[level] = append(levelDirs[level], dir)
+		}
+		return nil
+	})
+	if err == nil {
+		err = postorderByLevel(ctx, levelDirs, postorderFunc)
+	}
+	return dfsInfo.finish(ctx, d, err)
+}
+
+//postorderByLevel 从最深一层往上逐层调用postorderFunc,保证子节点先于父节点
+func postorderByLevel(ctx context.Context, levelDirs [][]*Dir, postorderFunc DirFunc) error {
+	if postorderFunc == nil {
+		return nil
+	}
+	for level := len(levelDirs) - 1; level >= 0; level-- {
+		for _, dir := range levelDirs[level] {
+			if err := ctxErr(ctx); err != nil {
+				return err
+			}
+			if err := skipPostorder(postorderFunc(ctx, dir)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
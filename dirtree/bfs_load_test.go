@@ -0,0 +1,81 @@
+package dirtree
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBFSLoad(t *testing.T) {
+	Convey("TestBFSLoad", t, func() {
+		Convey("TestBFSLoad success", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			totalSize, totalCount, err := dir.BFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil)
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 19)
+			So(totalSize, ShouldEqual, 10)
+			So(len(dir.GetAllFoldersAndFilesByBfs(nil)), ShouldEqual, 19)
+		})
+
+		Convey("TestBFSLoad pre and post order", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			var preIds, postIds []int64
+			preorder := func(ctx context.Context, dir *Dir) error {
+				preIds = append(preIds, dir.GetId())
+				return nil
+			}
+			postorder := func(ctx context.Context, dir *Dir) error {
+				postIds = append(postIds, dir.GetId())
+				return nil
+			}
+			_, _, err := dir.BFSLoad(nil, -1, -1, -1, getSubFilesMock, preorder, postorder)
+			So(err, ShouldBeNil)
+			So(preIds, ShouldResemble, []int64{0, 12, 13, 22, 23, 24, 33, 35, 36, 37})
+			So(postIds, ShouldResemble, []int64{33, 35, 36, 37, 22, 23, 24, 12, 13, 0})
+		})
+
+		Convey("TestBFSLoad pre-loaded subtree with depth gap", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			files, folders, _ := getSubFilesMock(nil, 1, 0)
+			So(dir.FillDirNoRecurse(nil, files, folders), ShouldBeNil)
+			dir.GetSubDirs()[0].depth = 3 //depth和父目录不连续
+			var postIds []int64
+			postorder := func(ctx context.Context, dir *Dir) error {
+				postIds = append(postIds, dir.GetId())
+				return nil
+			}
+			_, _, err := dir.BFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, postorder)
+			So(err, ShouldBeNil)
+			So(postIds, ShouldResemble, []int64{33, 35, 36, 37, 22, 23, 24, 12, 13, 0})
+		})
+
+		Convey("TestBFSLoad ErrFileNumLimit keeps shallow levels", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			_, _, err := dir.BFSLoad(nil, -1, 12, -1, getSubFilesMock, nil, nil)
+			So(errors.Is(err, ErrFileNumLimit), ShouldBeTrue)
+			So(dir.IsLoaded(), ShouldBeTrue)
+			for _, subDir := range dir.GetSubDirs() {
+				So(subDir.IsLoaded(), ShouldBeTrue)
+			}
+			for _, subDir := range dir.GetSubDirs()[0].GetSubDirs() {
+				if subDir.GetId() != 22 {
+					So(subDir.IsLoaded(), ShouldBeFalse)
+				}
+			}
+		})
+
+		Convey("TestBFSLoad ErrDirMaxDepthLimit", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			_, _, err := dir.BFSLoad(nil, 2, -1, -1, getSubFilesMock, nil, nil)
+			So(errors.Is(err, ErrMaxPathDepthLimit), ShouldBeTrue)
+			So(dir.GetSubDirs()[1].IsLoaded(), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,160 @@
+package dirtree
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+	ConcurrentLoad
+	并发加载,最多workerNum个goroutine同时调用retrieveNextDepthFiles,workerNum<=0时使用默认值。
+	maxDepth,numLimit,sizeLimit的含义和DFSLoad一样,所有goroutine共享同一份限额,
+	任意一个节点出错后会取消ctx并停止所有goroutine,返回第一个错误。
+	注意:preorderFunc和postorderFunc会被多个goroutine并发调用,
+	只保证父节点的preorderFunc先于子节点执行,子节点的postorderFunc都先于父节点执行。
+*/
+func (d *Dir) ConcurrentLoad(ctx context.Context, workerNum int,
+	maxDepth, numLimit, sizeLimit int64,
+	retrieveNextDepthFiles RetrieveNextDepthFilesFunc,
+	preorderFunc, postorderFunc DirFunc, opts ...LoadOption) (totalSize, totalCount int64, err error) {
+	if workerNum <= 0 {
+		workerNum = defWorkerNum
+	}
+	dfsInfo := d.newDfsLoadInfo(maxDepth, numLimit, sizeLimit, opts)
+	ctx, cancel := dfsInfo.opts.context(ctx)
+	defer cancel()
+	dfsInfo.start(ctx, d, "concurrent")
+	pool := &dirPool{
+		workerNum: workerNum,
+		visit: func(ctx context.Context, dir *Dir) ([]*Dir, error) {
+			if err := dir.loadNode(ctx, dfsInfo, retrieveNextDepthFiles, preorderFunc); err != nil {
+				return nil, err
+			}
+			return dir.subDirs, nil
+		},
+		finish: skipPostorderFunc(postorderFunc),
+	}
+	err = pool.run(ctx, d)
+	return dfsInfo.finish(ctx, d, err)
+}
+
+//dirTask 并发处理中的一个dir节点
+type dirTask struct {
+	dir     *Dir
+	parent  *dirTask
+	pending int64 //还没有完成的子节点数量,为0时才能对当前节点做后序处理
+}
+
+//dirPool 有界的goroutine池,父节点visit之后才会调度子节点,
+//所有子节点finish之后才会finish父节点
+type dirPool struct {
+	workerNum int
+	visit     func(ctx context.Context, dir *Dir) (subDirs []*Dir, err error) //前序处理,返回需要继续处理的子节点
+	finish    DirFunc                                                         //后序处理,可以为nil
+
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queue   []*dirTask //待处理的节点,后进先出,避免队列过大
+	running int        //排队中和处理中的节点数量
+	err     error      //第一个错误
+}
+
+func (p *dirPool) run(ctx context.Context, root *Dir) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	p.cond = sync.NewCond(&p.mu)
+	p.queue = []*dirTask{{dir: root}}
+	p.running = 1
+
+	var wg sync.WaitGroup
+	for i := 0; i < p.workerNum; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work(ctx, cancel)
+		}()
+	}
+	wg.Wait()
+	return p.err
+}
+
+func (p *dirPool) work(ctx context.Context, cancel context.CancelFunc) {
+	for {
+		task := p.pop()
+		if task == nil {
+			return
+		}
+		subDirs, err := p.visit(ctx, task.dir)
+		if err == errSkipSubtree { //跳过的节点不执行后序处理,直接算完成
+			subDirs, err = nil, p.complete(ctx, task.parent, task)
+		} else if err == nil && len(subDirs) == 0 {
+			err = p.complete(ctx, task, nil)
+		}
+		if err != nil {
+			p.fail(err)
+			cancel()
+			return
+		}
+		p.push(task, subDirs)
+	}
+}
+
+//pop 取出一个待处理节点,没有节点可处理时返回nil
+func (p *dirPool) pop() *dirTask {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.queue) == 0 && p.running > 0 && p.err == nil {
+		p.cond.Wait()
+	}
+	if p.err != nil || len(p.queue) == 0 {
+		return nil
+	}
+	task := p.queue[len(p.queue)-1]
+	p.queue = p.queue[:len(p.queue)-1]
+	return task
+}
+
+//push 把task的子节点加入队列,同时标记task的前序处理已完成
+func (p *dirPool) push(task *dirTask, subDirs []*Dir) {
+	task.pending = int64(len(subDirs)) //子节点入队之前设置,不会有并发
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, subDir := range subDirs {
+		p.queue = append(p.queue, &dirTask{dir: subDir, parent: task})
+	}
+	p.running += len(subDirs) - 1
+	p.cond.Broadcast()
+}
+
+//complete task及其所有子节点都已完成,执行后序处理,并向上完成所有子节点都已完成的祖先节点。
+//done不为nil时表示done是task的一个已完成(跳过)的子节点
+func (p *dirPool) complete(ctx context.Context, task, done *dirTask) error {
+	if done != nil && (task == nil || atomic.AddInt64(&task.pending, -1) != 0) {
+		return nil
+	}
+	for ; task != nil; task = task.parent {
+		if p.finish != nil {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := p.finish(ctx, task.dir); err != nil {
+				return err
+			}
+		}
+		if task.parent == nil || atomic.AddInt64(&task.parent.pending, -1) != 0 {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (p *dirPool) fail(err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err == nil {
+		p.err = err
+	}
+	p.cond.Broadcast()
+}
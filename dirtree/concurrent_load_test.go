@@ -0,0 +1,130 @@
+package dirtree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestConcurrentLoad(t *testing.T) {
+	Convey("TestConcurrentLoad", t, func() {
+		Convey("TestConcurrentLoad success", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			totalSize, totalCount, err := dir.ConcurrentLoad(nil, 4, -1, -1, -1, getSubFilesMock, nil, nil)
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 19)
+			So(totalSize, ShouldEqual, 10)
+			So(len(dir.GetAllFoldersAndFiles(nil)), ShouldEqual, 19)
+		})
+
+		Convey("TestConcurrentLoad bounded workers", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			var running, maxRunning int64
+			slowRetrieve := func(ctx context.Context, volumeId, folderId int64) (files, folders []*File, err error) {
+				curr := atomic.AddInt64(&running, 1)
+				defer atomic.AddInt64(&running, -1)
+				for {
+					old := atomic.LoadInt64(&maxRunning)
+					if curr <= old || atomic.CompareAndSwapInt64(&maxRunning, old, curr) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				return getSubFilesMock(ctx, volumeId, folderId)
+			}
+			_, totalCount, err := dir.ConcurrentLoad(nil, 2, -1, -1, -1, slowRetrieve, nil, nil)
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 19)
+			So(maxRunning, ShouldBeLessThanOrEqualTo, 2)
+		})
+
+		Convey("TestConcurrentLoad pre and post order", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			var mu sync.Mutex
+			preDone := make(map[int64]bool)
+			postDone := make(map[int64]bool)
+			var orderErr error
+			preorder := func(ctx context.Context, dir *Dir) error {
+				mu.Lock()
+				defer mu.Unlock()
+				if dir.originInfo.ParentId != unKnown && !preDone[dir.originInfo.ParentId] {
+					orderErr = fmt.Errorf("preorder of %d before parent", dir.GetId())
+				}
+				preDone[dir.GetId()] = true
+				return nil
+			}
+			postorder := func(ctx context.Context, dir *Dir) error {
+				mu.Lock()
+				defer mu.Unlock()
+				for _, subDir := range dir.subDirs {
+					if !postDone[subDir.GetId()] {
+						orderErr = fmt.Errorf("postorder of %d before child %d", dir.GetId(), subDir.GetId())
+					}
+				}
+				postDone[dir.GetId()] = true
+				return nil
+			}
+			_, _, err := dir.ConcurrentLoad(nil, 4, -1, -1, -1, getSubFilesMock, preorder, postorder)
+			So(err, ShouldBeNil)
+			So(orderErr, ShouldBeNil)
+			So(len(preDone), ShouldEqual, 10)
+			So(len(postDone), ShouldEqual, 10)
+		})
+
+		Convey("TestConcurrentLoad ErrDirMaxDepthLimit", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			_, _, err := dir.ConcurrentLoad(nil, 4, 2, -1, -1, getSubFilesMock, nil, nil)
+			So(errors.Is(err, ErrMaxPathDepthLimit), ShouldBeTrue)
+		})
+
+		Convey("TestConcurrentLoad ErrFileNumLimit", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			_, _, err := dir.ConcurrentLoad(nil, 4, -1, 18, -1, getSubFilesMock, nil, nil)
+			So(errors.Is(err, ErrFileNumLimit), ShouldBeTrue)
+		})
+
+		Convey("TestConcurrentLoad ErrTotalSizeLimit", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			_, _, err := dir.ConcurrentLoad(nil, 4, -1, -1, 9, getSubFilesMock, nil, nil)
+			So(errors.Is(err, ErrTotalSizeLimit), ShouldBeTrue)
+		})
+
+		Convey("TestConcurrentLoad stop on first error", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			errRetrieve := fmt.Errorf("retrieve failed")
+			var calls int64
+			failRetrieve := func(ctx context.Context, volumeId, folderId int64) (files, folders []*File, err error) {
+				atomic.AddInt64(&calls, 1)
+				if folderId == 12 {
+					return nil, nil, errRetrieve
+				}
+				if folderId != 0 {
+					<-ctx.Done() //其他goroutine等待取消
+					return nil, nil, ctx.Err()
+				}
+				return getSubFilesMock(ctx, volumeId, folderId)
+			}
+			_, _, err := dir.ConcurrentLoad(nil, 4, -1, -1, -1, failRetrieve, nil, nil)
+			So(errors.Is(err, errRetrieve), ShouldBeTrue)
+			var dirErr *DirError
+			So(errors.As(err, &dirErr), ShouldBeTrue)
+			So(dirErr.Op, ShouldEqual, "retrieve")
+			So(dirErr.FolderId, ShouldEqual, 12)
+			So(dirErr.Depth, ShouldEqual, 1)
+			So(atomic.LoadInt64(&calls), ShouldBeLessThanOrEqualTo, 3)
+		})
+	})
+}
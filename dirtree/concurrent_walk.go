@@ -0,0 +1,102 @@
+package dirtree
+
+import (
+	"context"
+	"sync"
+)
+
+//WalkOption ConcurrentWalk的选项
+type WalkOption func(opts *walkOptions)
+
+type walkOptions struct {
+	collectErrors bool //出错后继续遍历其他dir,最后返回*WalkErrors
+}
+
+//WithCollectErrors 出错后不停止遍历:preorderFunc出错的dir不再遍历它的子树,也不调用它的postorderFunc,
+//postorderFunc出错不影响祖先节点,最后把所有错误放在*WalkErrors里面返回
+func WithCollectErrors() WalkOption {
+	return func(opts *walkOptions) {
+		opts.collectErrors = true
+	}
+}
+
+/*
+	ConcurrentWalk
+	并发的dfs遍历,最多workerNum个goroutine同时调用preorderFunc和postorderFunc,workerNum<=0时使用默认值,
+	调用时需要已经load数据。保证父节点的preorderFunc先于子节点执行,所有子节点的postorderFunc都先于父节点执行,
+	兄弟节点之间没有顺序保证,所以preorderFunc和postorderFunc需要是并发安全的。
+	preorderFunc,postorderFunc返回SkipDir,SkipAll的含义和DfsWithFunc一样。
+	默认遇到第一个错误(包括没有加载的dir)就停止所有goroutine并返回这个错误,
+	使用WithCollectErrors时继续遍历其他dir,返回*WalkErrors。ctx取消时直接返回ctx.Err()。
+*/
+func (d *Dir) ConcurrentWalk(ctx context.Context, workerNum int,
+	preorderFunc, postorderFunc DirFunc, opts ...WalkOption) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if workerNum <= 0 {
+		workerNum = defWorkerNum
+	}
+	options := &walkOptions{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(options)
+		}
+	}
+
+	var mu sync.Mutex
+	var errs []error
+	collect := func(op string, dir *Dir, err error) error {
+		if err == nil || !options.collectErrors || err == errSkipSubtree || err == errStopLoad || ctx.Err() != nil {
+			return err
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, newDirError(op, dir, err))
+		if op == "postorder" {
+			return nil
+		}
+		return errSkipSubtree
+	}
+
+	pool := &dirPool{
+		workerNum: workerNum,
+		visit: func(ctx context.Context, dir *Dir) ([]*Dir, error) {
+			if err := ctxErr(ctx); err != nil {
+				return nil, err
+			}
+			if !dir.traversable() {
+				if options.collectErrors {
+					return nil, collect("traverse", dir, ErrDirNotLoad)
+				}
+				return nil, newDirError("traverse", dir, ErrDirNotLoad)
+			}
+			if preorderFunc != nil {
+				if err := skipSubtree(preorderFunc(ctx, dir)); err != nil {
+					return nil, collect("preorder", dir, err)
+				}
+			}
+			return dir.subDirs, nil
+		},
+	}
+	if postorderFunc != nil {
+		pool.finish = func(ctx context.Context, dir *Dir) error {
+			return collect("postorder", dir, skipPostorder(postorderFunc(ctx, dir)))
+		}
+	}
+
+	err := pool.run(ctx, d)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err == errStopLoad {
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return &WalkErrors{Errs: errs}
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package dirtree
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+//walkRecorder 并发安全地记录preorderFunc和postorderFunc的调用顺序
+type walkRecorder struct {
+	mu   sync.Mutex
+	pre  map[*Dir]int
+	post map[*Dir]int
+	seq  int
+	errs map[int64]error //preorderFunc或postorderFunc遇到这些id时返回对应的错误
+}
+
+func newWalkRecorder() *walkRecorder {
+	return &walkRecorder{pre: make(map[*Dir]int), post: make(map[*Dir]int), errs: make(map[int64]error)}
+}
+
+func (r *walkRecorder) record(m map[*Dir]int, dir *Dir) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seq++
+	m[dir] = r.seq
+}
+
+func (r *walkRecorder) preorder(ctx context.Context, dir *Dir) error {
+	r.record(r.pre, dir)
+	return r.errs[dir.GetId()]
+}
+
+func (r *walkRecorder) postorder(ctx context.Context, dir *Dir) error {
+	r.record(r.post, dir)
+	return r.errs[-dir.GetId()] //负数id表示postorderFunc的错误
+}
+
+func sortedIds(m map[*Dir]int) []int64 {
+	var ids []int64
+	for dir := range m {
+		ids = append(ids, dir.GetId())
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+func TestConcurrentWalk(t *testing.T) {
+	Convey("TestConcurrentWalk", t, func() {
+		buildTreeForTest()
+		dir := newNewVirtualDirForTest()
+		_, _, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil)
+		So(err, ShouldBeNil)
+
+		Convey("TestConcurrentWalk order", func() {
+			r := newWalkRecorder()
+			So(dir.ConcurrentWalk(nil, 4, r.preorder, r.postorder), ShouldBeNil)
+			So(sortedIds(r.pre), ShouldResemble, []int64{0, 12, 13, 22, 23, 24, 33, 35, 36, 37})
+			So(sortedIds(r.post), ShouldResemble, []int64{0, 12, 13, 22, 23, 24, 33, 35, 36, 37})
+			for d, seq := range r.pre {
+				if parent := d.Parent(); parent != nil {
+					So(r.pre[parent], ShouldBeLessThan, seq)
+					So(r.post[parent], ShouldBeGreaterThan, r.post[d])
+				}
+			}
+		})
+
+		Convey("TestConcurrentWalk skip", func() {
+			r := newWalkRecorder()
+			r.errs[22] = SkipDir
+			So(dir.ConcurrentWalk(nil, 4, r.preorder, r.postorder), ShouldBeNil)
+			So(sortedIds(r.pre), ShouldResemble, []int64{0, 12, 13, 22, 23, 24, 35, 36, 37})
+			So(sortedIds(r.post), ShouldResemble, []int64{0, 12, 13, 23, 24, 35, 36, 37})
+
+			r = newWalkRecorder()
+			r.errs[0] = SkipAll
+			So(dir.ConcurrentWalk(nil, 4, r.preorder, r.postorder), ShouldBeNil)
+			So(sortedIds(r.pre), ShouldResemble, []int64{0})
+			So(r.post, ShouldBeEmpty)
+		})
+
+		Convey("TestConcurrentWalk stop on first error", func() {
+			errStop := errors.New("stop")
+			r := newWalkRecorder()
+			r.errs[23] = errStop
+			So(dir.ConcurrentWalk(nil, 4, r.preorder, r.postorder), ShouldEqual, errStop)
+			So(r.post[dir], ShouldEqual, 0)
+		})
+
+		Convey("TestConcurrentWalk collect errors", func() {
+			errPre, errPost := errors.New("pre"), errors.New("post")
+			r := newWalkRecorder()
+			r.errs[23] = errPre
+			r.errs[-24] = errPost
+			err := dir.ConcurrentWalk(nil, 4, r.preorder, r.postorder, WithCollectErrors())
+			So(err, ShouldNotBeNil)
+			So(errors.Is(err, errPre), ShouldBeTrue)
+			So(errors.Is(err, errPost), ShouldBeTrue)
+			var walkErrs *WalkErrors
+			So(errors.As(err, &walkErrs), ShouldBeTrue)
+			So(walkErrs.Errs, ShouldHaveLength, 2)
+			var dirErr *DirError
+			So(errors.As(walkErrs.Errs[0], &dirErr), ShouldBeTrue)
+
+			So(sortedIds(r.pre), ShouldResemble, []int64{0, 12, 13, 22, 23, 24, 33, 37})
+			So(sortedIds(r.post), ShouldResemble, []int64{0, 12, 13, 22, 24, 33, 37})
+		})
+
+		Convey("TestConcurrentWalk unloaded dir", func() {
+			dir := unloadedDirForTest()
+
+			err = dir.ConcurrentWalk(nil, 4, nil, nil)
+			So(errors.Is(err, ErrDirNotLoad), ShouldBeTrue)
+
+			r := newWalkRecorder()
+			err = dir.ConcurrentWalk(nil, 4, r.preorder, r.postorder, WithCollectErrors())
+			So(errors.Is(err, ErrDirNotLoad), ShouldBeTrue)
+			So(sortedIds(r.post), ShouldResemble, []int64{0, 12, 13, 23, 24, 35, 36, 37})
+		})
+
+		Convey("TestConcurrentWalk context cancel", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			So(dir.ConcurrentWalk(ctx, 4, nil, nil), ShouldEqual, context.Canceled)
+		})
+	})
+}
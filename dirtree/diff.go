@@ -0,0 +1,126 @@
+package dirtree
+
+import (
+	"context"
+	"fmt"
+)
+
+//ChangeType Diff得到的变化类型
+type ChangeType int
+
+const (
+	ChangeAdded    ChangeType = iota + 1 //新增
+	ChangeMoved                          //同一个Id,所在的文件夹变了
+	ChangeRenamed                        //同一个Id,Name变了
+	ChangeModified                       //同一个Id,Version,Size或Mtime变了
+	ChangeRemoved                        //删除
+)
+
+var changeType2Str = map[ChangeType]string{
+	ChangeAdded:    "added",
+	ChangeMoved:    "moved",
+	ChangeRenamed:  "renamed",
+	ChangeModified: "modified",
+	ChangeRemoved:  "removed",
+}
+
+func (t ChangeType) String() string {
+	if s, ok := changeType2Str[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("ChangeType(%d)", int(t))
+}
+
+//Change 一个节点的一种变化,同一个节点可能同时有moved,renamed,modified几种变化
+type Change struct {
+	Type ChangeType
+	Id   int64
+	Old  *File //旧树中的节点,ChangeAdded时为nil
+	New  *File //新树中的节点,ChangeRemoved时为nil
+}
+
+func (c *Change) String() string {
+	return fmt.Sprintf("%v id=%d", c.Type, c.Id)
+}
+
+//diffNode Diff过程中记录的节点和它所在文件夹的id
+type diffNode struct {
+	file     *File
+	parentId int64
+}
+
+/*
+	Diff
+	比较同一个volume的两次加载结果,按Id匹配节点,两棵树都需要已经完整加载(遇到没有加载的dir返回ErrDirNotLoad)。
+	根节点本身只比较Name,Version,Size和Mtime,两个根节点的Id不同时返回ErrNotSameTree。
+	新增或删除的文件夹会把整个子树中的每个节点都列出来。返回的顺序可以直接按顺序重放:
+	1.ChangeAdded,新树中DFS顺序,父节点在子节点之前;
+	2.ChangeMoved,新树中DFS顺序,移动时新的父节点一定已经存在并且不会出现环;
+	3.ChangeRenamed,ChangeModified,新树中DFS顺序;
+	4.ChangeRemoved,旧树中DFS的逆序,子节点在父节点之前,移出被删文件夹的节点在第2步已经移走。
+*/
+func Diff(ctx context.Context, oldRoot, newRoot *Dir) ([]*Change, error) {
+	if oldRoot.originInfo.Id != newRoot.originInfo.Id {
+		return nil, ErrNotSameTree
+	}
+	oldNodes := make(map[int64]diffNode)
+	var oldOrder []*File
+	it := oldRoot.IterDFS(ctx)
+	for it.Next() {
+		if dir := it.Dir(); dir != nil && !dir.loaded { //被截断的dir会被迭代器当作叶子节点
+			return nil, newDirError("diff", dir, ErrDirNotLoad)
+		}
+		oldNodes[it.File().Id] = diffNode{file: it.File(), parentId: diffParentId(oldRoot, it)}
+		oldOrder = append(oldOrder, it.File())
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	var added, moved, changed []*Change
+	newIds := make(map[int64]bool)
+	it = newRoot.IterDFS(ctx)
+	for it.Next() {
+		if dir := it.Dir(); dir != nil && !dir.loaded {
+			return nil, newDirError("diff", dir, ErrDirNotLoad)
+		}
+		file := it.File()
+		newIds[file.Id] = true
+		old, ok := oldNodes[file.Id]
+		if !ok {
+			added = append(added, &Change{Type: ChangeAdded, Id: file.Id, New: file})
+			continue
+		}
+		if old.parentId != diffParentId(newRoot, it) {
+			moved = append(moved, &Change{Type: ChangeMoved, Id: file.Id, Old: old.file, New: file})
+		}
+		if old.file.Name != file.Name {
+			changed = append(changed, &Change{Type: ChangeRenamed, Id: file.Id, Old: old.file, New: file})
+		}
+		if old.file.Version != file.Version || old.file.Size != file.Size || old.file.Mtime != file.Mtime {
+			changed = append(changed, &Change{Type: ChangeModified, Id: file.Id, Old: old.file, New: file})
+		}
+	}
+	if err := it.Err(); err != nil {
+		return nil, err
+	}
+
+	changes := make([]*Change, 0, len(added)+len(moved)+len(changed))
+	changes = append(changes, added...)
+	changes = append(changes, moved...)
+	changes = append(changes, changed...)
+	for i := len(oldOrder) - 1; i >= 0; i-- {
+		if file := oldOrder[i]; !newIds[file.Id] {
+			changes = append(changes, &Change{Type: ChangeRemoved, Id: file.Id, Old: file})
+		}
+	}
+	return changes, nil
+}
+
+//diffParentId 节点所在文件夹的id,根节点本身返回unKnown
+func diffParentId(root *Dir, it *Iterator) int64 {
+	if it.Dir() == root {
+		return unKnown
+	}
+	return it.Parent().originInfo.Id
+}
@@ -0,0 +1,87 @@
+package dirtree
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func changeStrings(changes []*Change) []string {
+	var strs []string
+	for _, change := range changes {
+		strs = append(strs, change.String())
+	}
+	return strs
+}
+
+func TestDiff(t *testing.T) {
+	Convey("TestDiff", t, func() {
+		oldDir, _, err := NewDirFromFiles(nil, 0, 1, flatFilesForTest())
+		So(err, ShouldBeNil)
+		newDir, _, err := NewDirFromFiles(nil, 0, 1, flatFilesForTest())
+		So(err, ShouldBeNil)
+
+		Convey("TestDiff same tree", func() {
+			changes, err := Diff(nil, oldDir, newDir)
+			So(err, ShouldBeNil)
+			So(changes, ShouldBeEmpty)
+		})
+
+		Convey("TestDiff changes and replay", func() {
+			So(newDir.AddNode(nil, 23, &File{Id: 60, VolumeId: 1, Type: typeFolder, Name: "60"}), ShouldBeNil)
+			So(newDir.AddNode(nil, 60, &File{Id: 61, VolumeId: 1, Type: typeFile, Name: "61"}), ShouldBeNil)
+			So(newDir.MoveNode(nil, 10, 60), ShouldBeNil)
+			So(newDir.MoveNode(nil, 33, 24), ShouldBeNil)
+			So(newDir.RenameNode(nil, 21, "renamed"), ShouldBeNil)
+			file, _, _, err := newDir.findNode(nil, "modify", 34)
+			So(err, ShouldBeNil)
+			file.Version++
+			_, err = newDir.RemoveNode(nil, 22)
+			So(err, ShouldBeNil)
+
+			changes, err := Diff(nil, oldDir, newDir)
+			So(err, ShouldBeNil)
+			So(changeStrings(changes), ShouldResemble, []string{
+				"added id=60", "added id=61",
+				"moved id=10", "moved id=33",
+				"renamed id=21", "modified id=34",
+				"removed id=32", "removed id=31", "removed id=30", "removed id=22",
+			})
+			So(changes[2].Old.ParentId, ShouldEqual, 0)
+			So(changes[2].New.ParentId, ShouldEqual, 60)
+
+			//按顺序重放到旧树上,之后只剩下没有重放的modified
+			for _, change := range changes {
+				switch change.Type {
+				case ChangeAdded:
+					added := *change.New
+					So(oldDir.AddNode(nil, change.New.ParentId, &added), ShouldBeNil)
+				case ChangeMoved:
+					So(oldDir.MoveNode(nil, change.Id, change.New.ParentId), ShouldBeNil)
+				case ChangeRenamed:
+					So(oldDir.RenameNode(nil, change.Id, change.New.Name), ShouldBeNil)
+				case ChangeRemoved:
+					_, err := oldDir.RemoveNode(nil, change.Id)
+					So(err, ShouldBeNil)
+				}
+			}
+			changes, err = Diff(nil, oldDir, newDir)
+			So(err, ShouldBeNil)
+			So(changeStrings(changes), ShouldResemble, []string{"modified id=34"})
+		})
+
+		Convey("TestDiff errors", func() {
+			_, err := Diff(nil, oldDir, oldDir.GetSubDirs()[0])
+			So(err, ShouldEqual, ErrNotSameTree)
+
+			buildTreeForTest()
+			partial := newNewVirtualDirForTest()
+			var preIds []int64
+			_, _, err = partial.DFSLoad(nil, -1, -1, -1, getSubFilesMock, skipDirFunc(22, SkipDir, &preIds), nil)
+			So(err, ShouldBeNil)
+			_, err = Diff(nil, oldDir, partial)
+			So(errors.Is(err, ErrDirNotLoad), ShouldBeTrue)
+		})
+	})
+}
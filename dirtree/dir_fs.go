@@ -0,0 +1,278 @@
+package dirtree
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//ContentFunc 读取文件内容,返回的ReadCloser实现了io.Seeker时支持任意Seek
+type ContentFunc func(ctx context.Context, file *File) (io.ReadCloser, error)
+
+/*
+	DirFS
+	把目录树适配成fs.FS(同时实现fs.ReadDirFS和fs.StatFS),可以交给fs.WalkDir,fs.Glob,http.FS,template.ParseFS等使用。
+	路径用File.Name逐层拼接,文件内容来自content,还没加载的子目录在访问时通过retrieve懒加载(retrieve为nil时返回ErrDirNotLoad)。
+	注意:懒加载会修改目录树,DirFS内部加锁保证自身并发安全,但不能同时在其他地方修改同一棵树。
+*/
+type DirFS struct {
+	ctx      context.Context //懒加载和读取内容时使用
+	root     *Dir
+	content  ContentFunc
+	retrieve RetrieveNextDepthFilesFunc
+	pathOpts *pathOptions
+	mu       sync.Mutex //保护懒加载
+}
+
+//NewDirFS ctx用于懒加载和读取文件内容,content和retrieve都可以为nil
+func NewDirFS(ctx context.Context, root *Dir, content ContentFunc, retrieve RetrieveNextDepthFilesFunc) *DirFS {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return &DirFS{
+		ctx:      ctx,
+		root:     root,
+		content:  content,
+		retrieve: retrieve,
+		pathOpts: newPathOptions([]PathOption{WithLazyLoad(retrieve)}),
+	}
+}
+
+//Open 实现fs.FS
+func (f *DirFS) Open(name string) (fs.File, error) {
+	file, dir, err := f.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if dir != nil {
+		entries, err := f.readDir("open", name, dir)
+		if err != nil {
+			return nil, err
+		}
+		return &dirFSDir{info: newDirFSInfo(dir.originInfo, name), entries: entries}, nil
+	}
+	return &dirFSFile{fsys: f, file: file, info: newDirFSInfo(file, name)}, nil
+}
+
+//ReadDir 实现fs.ReadDirFS,按文件名排序
+func (f *DirFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	_, dir, err := f.resolve("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if dir == nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: ErrNotFolderType}
+	}
+	return f.readDir("readdir", name, dir)
+}
+
+//Stat 实现fs.StatFS
+func (f *DirFS) Stat(name string) (fs.FileInfo, error) {
+	file, dir, err := f.resolve("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	if dir != nil {
+		return newDirFSInfo(dir.originInfo, name), nil
+	}
+	return newDirFSInfo(file, name), nil
+}
+
+//resolve 按路径查找节点,文件夹返回dir,文件返回file
+func (f *DirFS) resolve(op, name string) (*File, *Dir, error) {
+	if !fs.ValidPath(name) {
+		return nil, nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	var segments []string
+	if name != "." {
+		segments = strings.Split(name, "/")
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, dir, err := f.root.lookupSegments(f.ctx, segments, f.pathOpts)
+	if err != nil {
+		return nil, nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return file, dir, nil
+}
+
+func (f *DirFS) readDir(op, name string, dir *Dir) ([]fs.DirEntry, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := dir.lazyLoad(f.ctx, f.retrieve); err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	entries := make([]fs.DirEntry, 0, len(dir.subDirs)+len(dir.subFiles))
+	for _, file := range dir.GetSubFoldersAndFiles() {
+		entries = append(entries, fs.FileInfoToDirEntry(newDirFSInfo(file, file.Name)))
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+//dirFSInfo 实现fs.FileInfo,Sys()返回*File
+type dirFSInfo struct {
+	file *File
+	name string
+}
+
+func newDirFSInfo(file *File, name string) *dirFSInfo {
+	if name != "." {
+		name = file.Name
+	}
+	return &dirFSInfo{file: file, name: name}
+}
+
+func (i *dirFSInfo) Name() string {
+	return i.name
+}
+
+func (i *dirFSInfo) Size() int64 {
+	return i.file.Size
+}
+
+func (i *dirFSInfo) Mode() fs.FileMode {
+	if i.file.IsFolder() {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+func (i *dirFSInfo) ModTime() time.Time {
+	return time.Unix(i.file.Mtime, 0)
+}
+
+func (i *dirFSInfo) IsDir() bool {
+	return i.file.IsFolder()
+}
+
+func (i *dirFSInfo) Sys() any {
+	return i.file
+}
+
+//dirFSDir 打开的文件夹,实现fs.ReadDirFile
+type dirFSDir struct {
+	info    *dirFSInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *dirFSDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *dirFSDir) Read(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+func (d *dirFSDir) Close() error {
+	return nil
+}
+
+func (d *dirFSDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remain := len(d.entries) - d.offset
+	if n <= 0 {
+		entries := d.entries[d.offset:]
+		d.offset = len(d.entries)
+		return entries, nil
+	}
+	if remain == 0 {
+		return nil, io.EOF
+	}
+	if n > remain {
+		n = remain
+	}
+	entries := d.entries[d.offset : d.offset+n]
+	d.offset += n
+	return entries, nil
+}
+
+//dirFSFile 打开的文件,第一次Read时才通过ContentFunc读取内容
+type dirFSFile struct {
+	fsys   *DirFS
+	file   *File
+	info   *dirFSInfo
+	reader io.ReadCloser
+	offset int64
+}
+
+func (f *dirFSFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *dirFSFile) open() error {
+	if f.reader != nil {
+		return nil
+	}
+	if f.fsys.content == nil {
+		return &fs.PathError{Op: "read", Path: f.info.name, Err: ErrNoContentFunc}
+	}
+	reader, err := f.fsys.content(f.fsys.ctx, f.file)
+	if err != nil {
+		return &fs.PathError{Op: "read", Path: f.info.name, Err: err}
+	}
+	f.reader = reader
+	return nil
+}
+
+func (f *dirFSFile) Read(p []byte) (int, error) {
+	if err := f.open(); err != nil {
+		return 0, err
+	}
+	n, err := f.reader.Read(p)
+	f.offset += int64(n)
+	return n, err
+}
+
+//Seek 内容实现了io.Seeker时直接Seek,否则只支持从头开始的偏移(重新打开后跳过offset字节)
+func (f *dirFSFile) Seek(offset int64, whence int) (int64, error) {
+	if err := f.open(); err != nil {
+		return 0, err
+	}
+	if seeker, ok := f.reader.(io.Seeker); ok {
+		pos, err := seeker.Seek(offset, whence)
+		if err == nil {
+			f.offset = pos
+		}
+		return pos, err
+	}
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.file.Size
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.info.name, Err: fs.ErrInvalid}
+	}
+	if offset < f.offset {
+		if err := f.Close(); err != nil {
+			return 0, err
+		}
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+		f.offset = 0
+	}
+	skipped, err := io.CopyN(io.Discard, f.reader, offset-f.offset)
+	f.offset += skipped
+	if err != nil && err != io.EOF {
+		return f.offset, err
+	}
+	return f.offset, nil
+}
+
+func (f *dirFSFile) Close() error {
+	if f.reader == nil {
+		return nil
+	}
+	err := f.reader.Close()
+	f.reader = nil
+	return err
+}
@@ -0,0 +1,106 @@
+package dirtree
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+//contentMock 文件内容是Size个文件名的第一个字符,不支持Seek
+func contentMock(ctx context.Context, file *File) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(bytes.Repeat([]byte(file.Name[:1]), int(file.Size)))), nil
+}
+
+func TestDirFS(t *testing.T) {
+	Convey("TestDirFS", t, func() {
+		Convey("TestDirFS fstest", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			_, _, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil)
+			So(err, ShouldBeNil)
+			fsys := NewDirFS(nil, dir, contentMock, nil)
+			So(fstest.TestFS(fsys, "0-10", "0-12/12-22/22-33/33-41", "0-13/13-24/24-37/37-42", "0-12/12-23/23-35"), ShouldBeNil)
+		})
+
+		Convey("TestDirFS WalkDir and Glob", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			_, _, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil)
+			So(err, ShouldBeNil)
+			fsys := NewDirFS(nil, dir, contentMock, nil)
+			var paths []string
+			err = fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+				paths = append(paths, path)
+				return err
+			})
+			So(err, ShouldBeNil)
+			So(len(paths), ShouldEqual, 20)
+			So(paths[:4], ShouldResemble, []string{".", "0-10", "0-11", "0-12"})
+
+			matches, err := fs.Glob(fsys, "0-12/*/22-3?")
+			So(err, ShouldBeNil)
+			So(matches, ShouldResemble, []string{"0-12/12-22/22-30", "0-12/12-22/22-31", "0-12/12-22/22-32", "0-12/12-22/22-33"})
+
+			info, err := fs.Stat(fsys, "0-12/12-22")
+			So(err, ShouldBeNil)
+			So(info.IsDir(), ShouldBeTrue)
+			So(info.Sys().(*File).Id, ShouldEqual, 22)
+			data, err := fs.ReadFile(fsys, "0-12/12-22/22-30")
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, "2")
+
+			_, err = fs.Stat(fsys, "0-12/none")
+			So(errors.Is(err, fs.ErrNotExist), ShouldBeTrue)
+			_, err = fsys.Open("/0-12")
+			So(errors.Is(err, fs.ErrInvalid), ShouldBeTrue)
+		})
+
+		Convey("TestDirFS lazy load", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			var retrieved []int64
+			retrieve := func(ctx context.Context, volumeId, folderId int64) (files, folders []*File, err error) {
+				retrieved = append(retrieved, folderId)
+				return getSubFilesMock(ctx, volumeId, folderId)
+			}
+			fsys := NewDirFS(nil, dir, contentMock, retrieve)
+			info, err := fs.Stat(fsys, "0-12/12-22/22-33/33-41")
+			So(err, ShouldBeNil)
+			So(info.Size(), ShouldEqual, 1)
+			So(retrieved, ShouldResemble, []int64{0, 12, 22, 33})
+			So(dir.GetSubDirs()[1].IsLoaded(), ShouldBeFalse)
+
+			entries, err := fs.ReadDir(fsys, "0-13")
+			So(err, ShouldBeNil)
+			So(len(entries), ShouldEqual, 1)
+			So(entries[0].Name(), ShouldEqual, "13-24")
+
+			_, err = fs.Stat(NewDirFS(nil, newNewVirtualDirForTest(), nil, nil), "0-12")
+			So(errors.Is(err, ErrDirNotLoad), ShouldBeTrue)
+		})
+
+		Convey("TestDirFS http.FileServer", func() {
+			buildTreeForTest()
+			fsys := NewDirFS(nil, newNewVirtualDirForTest(), contentMock, getSubFilesMock)
+			server := httptest.NewServer(http.FileServer(http.FS(fsys)))
+			defer server.Close()
+			resp, err := http.Get(server.URL + "/0-12/12-22/22-30")
+			So(err, ShouldBeNil)
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			So(resp.StatusCode, ShouldEqual, http.StatusOK)
+			So(string(body), ShouldEqual, "2")
+
+			_, err = fs.ReadFile(NewDirFS(nil, newNewVirtualDirForTest(), nil, getSubFilesMock), "0-10")
+			So(errors.Is(err, ErrNoContentFunc), ShouldBeTrue)
+		})
+	})
+}
@@ -2,12 +2,16 @@ package dirtree
 
 import (
 	"context"
-	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
 )
 
 const (
 	defMaxDepth      = 100    //最大递归查询深度100
 	defMaxTotalCount = 100000 //最多十万
+	defWorkerNum     = 8      //并发加载默认的goroutine数量
+	defBatchSize     = 500    //批量加载默认每次查询的folder数量
 
 	unKnown = -1
 
@@ -15,21 +19,24 @@ const (
 	typeFolder = 2
 )
 
+//UnlimitedDepth 作为maxDepth传入(或者设置为默认值)时不限制加载深度
+const UnlimitedDepth int64 = math.MaxInt64
+
+var defaultMaxDepth int64 = defMaxDepth //maxDepth小于0时使用的默认值,通过SetDefaultMaxDepth修改
+
+//SetDefaultMaxDepth 设置maxDepth小于0时使用的默认深度限制,可以是UnlimitedDepth,depth小于0时恢复为defMaxDepth
+func SetDefaultMaxDepth(depth int64) {
+	if depth < 0 {
+		depth = defMaxDepth
+	}
+	atomic.StoreInt64(&defaultMaxDepth, depth)
+}
+
 var type2Str = map[int]string{
 	typeFile:   "file",
 	typeFolder: "folder",
 }
 
-var (
-	errDirAlreadyLoaded             = fmt.Errorf("dir already loaded")
-	errDirNotLoad                   = fmt.Errorf("dir not load")
-	errNoRetrieveNextDepthFilesFunc = fmt.Errorf("no RetrieveNextDepthFilesFunc")
-	errNotFolderType                = fmt.Errorf("not folder type")
-	errMaxPathDepthLimit            = fmt.Errorf("max path depth limit")
-	errFileNumLimit                 = fmt.Errorf("file num list")
-	errTotalSizeLimit               = fmt.Errorf("total size limit")
-)
-
 type (
 	//DirFunc 处理目录的func
 	DirFunc func(ctx context.Context, dir *Dir) error
@@ -64,13 +71,18 @@ func (r *File) TypeString() string {
 }
 
 type Dir struct {
-	originInfo *File   //当前目录的原始信息
-	subDirs    []*Dir  //子目录(即子文件夹)
-	subFiles   []*File //子文件(纯文件,没有文件夹)
-	depth      int64   //当前在目录树中的层级,-1表示未知,0表示树根节点
-	count      int64   //当前层级文件(夹)数量,-1表示未知
-	size       int64   //当前层级文件大小,-1表示未知
-	loaded     bool    //表示当前层级是否已经加载数据
+	originInfo *File      //当前目录的原始信息
+	subDirs    []*Dir     //子目录(即子文件夹)
+	subFiles   []*File    //子文件(纯文件,没有文件夹)
+	depth      int64      //当前在目录树中的层级,-1表示未知,0表示树根节点
+	count      int64      //当前层级文件(夹)数量,-1表示未知
+	size       int64      //当前层级文件大小,-1表示未知
+	loaded     bool       //表示当前层级是否已经加载数据
+	truncated  error      //部分加载模式下被截断的原因,nil表示没有被截断
+	skipped    bool       //加载时preorderFunc返回SkipDir而没有加载
+	index      *Index     //整棵树共享的id索引,nil表示没有开启
+	parent     *Dir       //父目录,根节点为nil
+	totals     *dirTotals //整个子树(已加载部分)的累计统计
 }
 
 /*********************************
@@ -123,6 +135,7 @@ func NewDir(folder *File, depth, count, size int64) *Dir {
 		count:      count,
 		size:       size,
 		loaded:     false,
+		totals:     &dirTotals{unloaded: 1},
 	}
 	return dir
 }
@@ -147,10 +160,21 @@ func (d *Dir) IsVirtualDir() bool {
 	return d.originInfo.Id <= 0
 }
 
-//FillDirNoRecurse 手动填充当前dir信息,不递归
+//FillDirNoRecurse 手动填充当前dir信息,不递归,同时更新当前dir以及所有祖先节点的累计统计
 func (d *Dir) FillDirNoRecurse(ctx context.Context, subFiles, subFolders []*File) error {
+	if err := d.fillNoRecurse(ctx, subFiles, subFolders); err != nil {
+		return err
+	}
+	delta := d.ownTotals()
+	delta.unloaded = int64(len(d.subDirs)) - 1 //新的子目录都未加载,d自己变为已加载
+	d.addTotals(delta)
+	return nil
+}
+
+//fillNoRecurse 填充当前dir信息,不更新累计统计,加载方法在结束时统一计算
+func (d *Dir) fillNoRecurse(ctx context.Context, subFiles, subFolders []*File) error {
 	if d.loaded {
-		return errDirAlreadyLoaded
+		return newDirError("fill", d, ErrDirAlreadyLoaded)
 	}
 	// 初始化
 	d.size = 0 //初始化size
@@ -163,9 +187,14 @@ func (d *Dir) FillDirNoRecurse(ctx context.Context, subFiles, subFolders []*File
 	}
 	for _, folder := range subFolders {
 		tmpDir := NewDir(folder, d.depth+1, unKnown, unKnown) //把folder转成dir
+		tmpDir.index = d.index
+		tmpDir.parent = d
 		d.subDirs = append(d.subDirs, tmpDir)
 	}
 	d.loaded = true
+	if d.index != nil {
+		d.index.addChildren(d)
+	}
 	return nil
 }
 
@@ -208,82 +237,47 @@ func (d *Dir) GetSubFoldersAndFiles() []*File {
 //GetAllPureFiles 获取所有纯文件
 func (d *Dir) GetAllPureFiles(ctx context.Context) []*File {
 	var allFiles []*File
-	addSubFiles := func(ctx context.Context, dir *Dir) error {
-		allFiles = append(allFiles, dir.subFiles...)
-		return nil
+	for it := d.IterDFS(ctx); it.Next(); {
+		if it.Dir() == nil {
+			allFiles = append(allFiles, it.File())
+		}
 	}
-	_ = d.DfsWithFunc(ctx, addSubFiles, nil)
 	return allFiles
 }
 
 //GetAllFolders 注意:不包括虚节点,DFS顺序返回的
 func (d *Dir) GetAllFolders(ctx context.Context) []*File {
 	var allFiles []*File
-
-	if !d.IsVirtualDir() { //非虚拟的才包括根文件夹
-		allFiles = append(allFiles, d.originInfo)
-	}
-
-	addSubFiles := func(ctx context.Context, dir *Dir) error {
-		allFiles = append(allFiles, dir.GetSubFolders()...)
-		return nil
+	for it := d.IterDFS(ctx); it.Next(); {
+		if it.Dir() != nil {
+			allFiles = append(allFiles, it.File())
+		}
 	}
-	_ = d.DfsWithFunc(ctx, addSubFiles, nil)
 	return allFiles
 }
 
 //GetAllFoldersAndFiles 注意:不包括虚节点,dir类型DFS顺序返回的,可参考file_dir_test.go
 func (d *Dir) GetAllFoldersAndFiles(ctx context.Context) []*File {
 	var allFiles []*File
-	if !d.IsVirtualDir() { //非虚拟的才包括根文件夹
-		allFiles = append(allFiles, d.originInfo)
-	}
-	addSubFoldersAndFiles := func(ctx context.Context, dir *Dir) error {
-		allFiles = append(allFiles, dir.GetSubFoldersAndFiles()...)
-		return nil
+	for it := d.IterDFS(ctx); it.Next(); {
+		allFiles = append(allFiles, it.File())
 	}
-	_ = d.DfsWithFunc(ctx, addSubFoldersAndFiles, nil)
 	return allFiles
 }
 
 //GetAllFoldersAndFilesByBfs 注意:不包括虚节点,BFS顺序返回
 func (d *Dir) GetAllFoldersAndFilesByBfs(ctx context.Context) []*File {
 	var allFiles []*File
-	if !d.IsVirtualDir() { //非虚拟的才包括根文件夹
-		allFiles = append(allFiles, d.originInfo)
-	}
-	addSubFoldersAndFiles := func(ctx context.Context, dir *Dir) error {
-		allFiles = append(allFiles, dir.GetSubFoldersAndFiles()...)
-		return nil
+	for it := d.IterBFS(ctx); it.Next(); {
+		allFiles = append(allFiles, it.File())
 	}
-	_ = d.BfsWithFunc(ctx, addSubFoldersAndFiles)
 	return allFiles
 }
 
 //GetAllFoldersAndFilesOnLevel 层级维度的返回 注意:不包括虚节点,BFS顺序返回,而且是带层级的
 func (d *Dir) GetAllFoldersAndFilesOnLevel(ctx context.Context) (levelAllFiles [][]*File) {
-	var minLevel int64 //最小层级
-	levelFilesMap := make(map[int64][]*File)
-	if !d.IsVirtualDir() { //非虚拟的才包括根文件夹,第一层
-		minLevel = d.depth
-		levelFilesMap[d.depth] = []*File{d.originInfo}
-	} else {
-		minLevel = d.depth + 1
-	}
-
-	maxLevel := minLevel
-
-	addSubFoldersAndFiles := func(ctx context.Context, dir *Dir) error {
-		nextLevel := dir.depth + 1
-		if nextLevel > maxLevel {
-			maxLevel = nextLevel
-		}
-		levelFilesMap[nextLevel] = append(levelFilesMap[nextLevel], dir.GetSubFoldersAndFiles()...)
-		return nil
-	}
-	_ = d.BfsWithFunc(ctx, addSubFoldersAndFiles)
-	for level := minLevel; level <= maxLevel; level++ {
-		levelAllFiles = append(levelAllFiles, levelFilesMap[level])
+	for it := d.IterLevels(ctx); it.Next(); {
+		levelAllFiles = append(levelAllFiles, it.Files())
 	}
 	return levelAllFiles
 }
@@ -311,15 +305,56 @@ type dsfLoadInfo struct {
 	numLimit   int64
 	totalCount int64
 	totalSize  int64
+	opts       *loadOptions
+	logger     Logger
+	hits       []*LimitError //部分加载模式下触发限制的位置
+	stopReason error         //部分加载模式下停止加载的原因
+	mu         sync.Mutex    //并发加载时保护totalCount,totalSize和hits
 }
 
-//FileNumLimit
-func (d *Dir) DFSLoad(ctx context.Context,
-	maxDepth, numLimit, sizeLimit int64,
-	retrieveNextDepthFiles RetrieveNextDepthFilesFunc,
-	preorderFunc, postorderFunc DirFunc) (totalSize, totalCount int64, err error) {
+//checkDepth 检查深度限制,可并发调用
+func (info *dsfLoadInfo) checkDepth(ctx context.Context, dir *Dir) error {
+	if dir.depth < info.maxDepth {
+		return nil
+	}
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	return info.limitHit(ctx, dir, ErrMaxPathDepthLimit, info.maxDepth, info.totalCount, info.totalSize)
+}
+
+//addAndCheck 累加dir的统计并检查数量和大小限制,可并发调用
+func (info *dsfLoadInfo) addAndCheck(ctx context.Context, dir *Dir) error {
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	info.totalCount += dir.count
+	info.totalSize += dir.size
+	return info.checkLimit(ctx, dir, info.totalCount, info.totalSize)
+}
+
+//add 只累加dir的统计,不检查限制,可并发调用
+func (info *dsfLoadInfo) add(dir *Dir) {
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	info.totalCount += dir.count
+	info.totalSize += dir.size
+}
+
+//checkLimit 检查数量和大小限制,调用时需要持有info.mu
+func (info *dsfLoadInfo) checkLimit(ctx context.Context, dir *Dir, totalCount, totalSize int64) error {
+	if totalCount > info.numLimit {
+		return info.limitHit(ctx, dir, ErrFileNumLimit, info.numLimit, totalCount, totalSize)
+	}
+
+	if info.sizeLimit >= 0 && totalSize > info.sizeLimit {
+		return info.limitHit(ctx, dir, ErrTotalSizeLimit, info.sizeLimit, totalCount, totalSize)
+	}
+	return nil
+}
+
+//newDfsLoadInfo 处理默认值,并且非虚拟目录算上根节点
+func (d *Dir) newDfsLoadInfo(maxDepth, numLimit, sizeLimit int64, opts []LoadOption) *dsfLoadInfo {
 	if maxDepth < 0 {
-		maxDepth = defMaxDepth
+		maxDepth = atomic.LoadInt64(&defaultMaxDepth)
 	}
 	if numLimit < 0 {
 		numLimit = defMaxTotalCount
@@ -330,15 +365,30 @@ func (d *Dir) DFSLoad(ctx context.Context,
 		numLimit:   numLimit,
 		totalCount: 0,
 		totalSize:  0,
+		opts:       newLoadOptions(opts),
+	}
+	dfsInfo.logger = dfsInfo.opts.logger
+	if dfsInfo.logger == nil {
+		dfsInfo.logger = getLogger()
 	}
 	if !d.IsVirtualDir() {
 		dfsInfo.totalCount += 1 //非虚拟目录,算上根节点
 	}
+	return dfsInfo
+}
+
+//DFSLoad 深度优先加载,maxDepth,numLimit小于0时使用默认值,sizeLimit小于0表示不限制,
+//maxDepth传UnlimitedDepth时不限制深度,使用显式的栈实现,目录再深也不会递归
+func (d *Dir) DFSLoad(ctx context.Context,
+	maxDepth, numLimit, sizeLimit int64,
+	retrieveNextDepthFiles RetrieveNextDepthFilesFunc,
+	preorderFunc, postorderFunc DirFunc, opts ...LoadOption) (totalSize, totalCount int64, err error) {
+	dfsInfo := d.newDfsLoadInfo(maxDepth, numLimit, sizeLimit, opts)
+	ctx, cancel := dfsInfo.opts.context(ctx)
+	defer cancel()
+	dfsInfo.start(ctx, d, "dfs")
 	err = d.dfsLoadDir(ctx, dfsInfo, retrieveNextDepthFiles, preorderFunc, postorderFunc)
-	if err != nil {
-		return 0, 0, err
-	}
-	return dfsInfo.totalSize, dfsInfo.totalCount, nil
+	return dfsInfo.finish(ctx, d, err)
 }
 
 func (d *Dir) DoDfsPreorderFunc(ctx context.Context, preorderFunc DirFunc) (err error) {
@@ -349,11 +399,19 @@ func (d *Dir) DoDfsPostorderFunc(ctx context.Context, postorderFunc DirFunc) (er
 	return d.DfsWithFunc(ctx, nil, postorderFunc)
 }
 
+//GetTotalSizeAndCount 子树(已加载部分)的文件大小之和以及文件(夹)数量,整个子树都已加载时直接使用累计统计,否则遍历子树
 func (d *Dir) GetTotalSizeAndCount(ctx context.Context) (totalSize, totalCount int64, err error) {
 	if !d.IsVirtualDir() {
 		totalCount += 1 //非虚拟目录,算上根节点
 	}
+	if d.fullyLoaded() { //整个子树都已加载时直接使用累计统计
+		t := d.totals.load()
+		return t.size, totalCount + t.files + t.folders, nil
+	}
 	addSizeAndCount := func(ctx context.Context, dir *Dir) error {
+		if !dir.loaded { //被截断的dir,size和count未知
+			return nil
+		}
 		totalSize += dir.size
 		totalCount += dir.count
 		return nil
@@ -369,106 +427,206 @@ func (d *Dir) GetTotalSizeAndCount(ctx context.Context) (totalSize, totalCount i
 func (d *Dir) dfsLoadDir(ctx context.Context,
 	dfsInfo *dsfLoadInfo, retrieveNextDepthFiles RetrieveNextDepthFilesFunc,
 	preorderFunc, postorderFunc DirFunc) error {
-	if !d.originInfo.IsFolder() {
-		return errNotFolderType
+	var leave func(dir *Dir) error
+	if postorderFunc != nil {
+		leave = func(dir *Dir) error {
+			if err := ctxErr(ctx); err != nil {
+				return err
+			}
+			return skipPostorder(postorderFunc(ctx, dir))
+		}
 	}
+	return d.dfsStack(func(dir *Dir) error {
+		return dir.loadNode(ctx, dfsInfo, retrieveNextDepthFiles, preorderFunc)
+	}, leave)
+}
 
-	if d.depth >= dfsInfo.maxDepth {
-		fmt.Printf("dfsLoadDir max recursion depth touch,currDepth=%d,maxDepth=%d", d.depth, dfsInfo.maxDepth)
-		return errMaxPathDepthLimit
-	}
+//dfsFrame dfsStack栈里的一个dir,next是下一个要处理的子目录
+type dfsFrame struct {
+	dir  *Dir
+	next int
+}
 
-	if preorderFunc != nil {
-		if err := preorderFunc(ctx, d); err != nil {
-			return err
+/*
+	dfsStack
+	用显式的栈做dfs,不会随着目录深度递归。对每个dir先调用enter,然后处理它的subDirs(enter里面可以加载dir),
+	最后调用leave,leave可以为nil。enter返回errSkipSubtree时跳过这个dir的子树并且不调用它的leave,
+	其他错误直接返回。
+*/
+func (d *Dir) dfsStack(enter, leave func(dir *Dir) error) error {
+	if err := enter(d); err != nil {
+		if err == errSkipSubtree {
+			return nil
+		}
+		return err
+	}
+	stack := []dfsFrame{{dir: d}}
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.next < len(top.dir.subDirs) {
+			subDir := top.dir.subDirs[top.next]
+			top.next++
+			if err := enter(subDir); err != nil {
+				if err == errSkipSubtree {
+					continue
+				}
+				return err
+			}
+			stack = append(stack, dfsFrame{dir: subDir})
+			continue
+		}
+		dir := top.dir
+		stack = stack[:len(stack)-1]
+		if leave != nil {
+			if err := leave(dir); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
+}
+
+//loadNode 加载单个dir节点(不递归):检查深度,执行preorderFunc,拉取下一层数据并累加统计
+func (d *Dir) loadNode(ctx context.Context,
+	dfsInfo *dsfLoadInfo, retrieveNextDepthFiles RetrieveNextDepthFilesFunc,
+	preorderFunc DirFunc) error {
+	if err := d.beforeLoad(ctx, dfsInfo, preorderFunc); err != nil {
+		return err
+	}
 
 	if !d.loaded {
-		if retrieveNextDepthFiles == nil {
-			return errNoRetrieveNextDepthFilesFunc
-		}
-		files, folders, err := retrieveNextDepthFiles(ctx, d.originInfo.VolumeId, d.originInfo.Id)
-		if err != nil {
+		files, folders, err := dfsInfo.retrieve(ctx, d, retrieveNextDepthFiles)
+		if err != nil && err != errStopLoad {
 			return err
 		}
-		err = d.FillDirNoRecurse(ctx, files, folders)
-		if err != nil {
+		if fillErr := d.fillNoRecurse(ctx, files, folders); fillErr != nil {
+			return fillErr
+		}
+		if err == errStopLoad { //部分加载模式下分页拉取时触发了限制,保留已拉取的部分
+			dfsInfo.add(d)
 			return err
 		}
 	}
 
-	dfsInfo.totalCount += d.count
-	dfsInfo.totalSize += d.size
+	return dfsInfo.addAndCheck(ctx, d)
+}
 
-	if dfsInfo.totalCount > dfsInfo.numLimit {
-		fmt.Printf("dfsLoadDir totalCount=%d,fileNumLimit=%d,", dfsInfo.totalCount, dfsInfo.numLimit)
-		return errFileNumLimit
+//beforeLoad 拉取数据之前的检查:ctx,类型,深度限制,然后执行preorderFunc
+func (d *Dir) beforeLoad(ctx context.Context, dfsInfo *dsfLoadInfo, preorderFunc DirFunc) error {
+	if err := ctxErr(ctx); err != nil {
+		return err
 	}
 
-	if dfsInfo.sizeLimit >= 0 && dfsInfo.totalSize > dfsInfo.sizeLimit {
-		fmt.Printf("dfsLoadDir totalCount=%d,totalSizeLimit=%d,", dfsInfo.totalCount, dfsInfo.sizeLimit)
-		return errTotalSizeLimit
+	if !d.originInfo.IsFolder() {
+		return newDirError("load", d, ErrNotFolderType)
 	}
 
-	for _, subDir := range d.subDirs {
-		if err := subDir.dfsLoadDir(ctx, dfsInfo, retrieveNextDepthFiles, preorderFunc, postorderFunc); err != nil {
-			return err
-		}
+	if err := dfsInfo.checkDepth(ctx, d); err != nil {
+		return err
 	}
 
-	if postorderFunc != nil {
-		if err := postorderFunc(ctx, d); err != nil {
+	if preorderFunc != nil {
+		if err := skipSubtree(preorderFunc(ctx, d)); err != nil {
+			if err == errSkipSubtree {
+				d.skipped = true
+			}
 			return err
 		}
 	}
 	return nil
 }
 
-//DfsWithFunc dfs遍历(针对dir节点),调用时需要已经load数据
-func (d *Dir) DfsWithFunc(ctx context.Context, preorderFunc, postorderFunc DirFunc) (err error) {
-	if !d.loaded {
-		return errDirNotLoad
+//retrieve 拉取dir的下一层数据,设置了WithPageRetrieve时分页拉取
+func (info *dsfLoadInfo) retrieve(ctx context.Context, dir *Dir,
+	retrieveNextDepthFiles RetrieveNextDepthFilesFunc) (files, folders []*File, err error) {
+	if info.opts.retrievePage != nil {
+		return info.retrievePages(ctx, dir)
 	}
-	if preorderFunc != nil {
-		if err = preorderFunc(ctx, d); err != nil {
-			return err
-		}
+	if retrieveNextDepthFiles == nil {
+		return nil, nil, newDirError("load", dir, ErrNoRetrieveNextDepthFilesFunc)
 	}
-
-	for _, dir := range d.subDirs {
-		if err = dir.DfsWithFunc(ctx, preorderFunc, postorderFunc); err != nil {
-			return err
-		}
+	files, folders, err = retrieveNextDepthFiles(ctx, dir.originInfo.VolumeId, dir.originInfo.Id)
+	if err != nil {
+		info.logRetrieveFailed(ctx, dir, err)
+		return nil, nil, newDirError("retrieve", dir, err)
 	}
+	return files, folders, nil
+}
+
+//DfsWithFunc dfs遍历(针对dir节点),调用时需要已经load数据(被截断或者被跳过的dir当作叶子节点),DirFunc可以返回SkipDir或SkipAll
+func (d *Dir) DfsWithFunc(ctx context.Context, preorderFunc, postorderFunc DirFunc) error {
+	return d.dfsWithFunc(ctx, preorderFunc, postorderFunc, nil)
+}
 
+//dfsWithFunc skipped里的dir在检查是否已经load之前就跳过,不调用preorderFunc和postorderFunc
+func (d *Dir) dfsWithFunc(ctx context.Context, preorderFunc, postorderFunc DirFunc, skipped map[*Dir]bool) error {
+	var leave func(dir *Dir) error
 	if postorderFunc != nil {
-		if err = postorderFunc(ctx, d); err != nil {
+		leave = func(dir *Dir) error {
+			if err := ctxErr(ctx); err != nil {
+				return err
+			}
+			return skipPostorder(postorderFunc(ctx, dir))
+		}
+	}
+	err := d.dfsStack(func(dir *Dir) error {
+		if err := ctxErr(ctx); err != nil {
 			return err
 		}
+		if skipped[dir] {
+			delete(skipped, dir)
+			return errSkipSubtree
+		}
+		if !dir.traversable() {
+			return newDirError("traverse", dir, ErrDirNotLoad)
+		}
+		if preorderFunc != nil {
+			return skipSubtree(preorderFunc(ctx, dir))
+		}
+		return nil
+	}, leave)
+	if err == errStopLoad {
+		return nil
 	}
-
-	return nil
+	return err
 }
 
-//BfsWithFunc Bfs遍历,注意:调用时需要已经load数据
+//BfsWithFunc Bfs遍历,注意:调用时需要已经load数据,callBack可以返回SkipDir或SkipAll
 func (d *Dir) BfsWithFunc(ctx context.Context, callBack DirFunc) (err error) {
-	if !d.loaded {
-		return errDirNotLoad
+	if !d.traversable() {
+		return newDirError("traverse", d, ErrDirNotLoad)
 	}
+	err = d.bfsLevels(func(dir *Dir, _ int) error {
+		if err := ctxErr(ctx); err != nil {
+			return err
+		}
+		if callBack != nil {
+			return skipSubtree(callBack(ctx, dir))
+		}
+		return nil
+	})
+	if err == errStopLoad {
+		return nil
+	}
+	return err
+}
+
+//bfsLevels 按层遍历,先对dir调用visit再取它的subDirs放入下一层,所以visit里面可以加载dir。
+//level是相对d的层数(d为0),不依赖dir.depth。visit返回errSkipSubtree时不遍历dir的子目录
+func (d *Dir) bfsLevels(visit func(dir *Dir, level int) error) error {
 	currDepthDirs := []*Dir{d} //当前层Dir
 
-	for {
+	for level := 0; ; level++ {
 		if len(currDepthDirs) == 0 {
 			break
 		}
 		var nextDepthDirs []*Dir //下一层Dir
 		for _, dir := range currDepthDirs {
-			if callBack != nil {
-				err = callBack(ctx, dir)
-				if err != nil {
-					return err
+			if err := visit(dir, level); err != nil {
+				if err == errSkipSubtree {
+					continue
 				}
+				return err
 			}
 			nextDepthDirs = append(nextDepthDirs, dir.subDirs...)
 		}
@@ -477,3 +635,11 @@ func (d *Dir) BfsWithFunc(ctx context.Context, callBack DirFunc) (err error) {
 
 	return nil
 }
+
+//ctxErr ctx被取消或超时时返回ctx.Err(),兼容ctx为nil
+func ctxErr(ctx context.Context) error {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Err()
+}
@@ -2,9 +2,11 @@ package dirtree
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	. "github.com/smartystreets/goconvey/convey"
 	"testing"
+	"time"
 )
 
 // 这里使用父子层级模拟树
@@ -169,14 +171,49 @@ func TestFileDirLoad(t *testing.T) {
 			buildTreeForTest()
 			dir := newNewVirtualDirForTest()
 			_, _, err := dir.DFSLoad(nil, 2, -1, -1, getSubFilesMock, nil, nil)
-			So(err, ShouldEqual, errMaxPathDepthLimit)
+			So(errors.Is(err, ErrMaxPathDepthLimit), ShouldBeTrue)
 		})
 
 		Convey("TestFileDirLoad ErrFileNumLimit", func() {
 			buildTreeForTest()
 			dir := newNewVirtualDirForTest()
 			_, _, err := dir.DFSLoad(nil, -1, 18, -1, getSubFilesMock, nil, nil)
-			So(err, ShouldEqual, errFileNumLimit)
+			So(errors.Is(err, ErrFileNumLimit), ShouldBeTrue)
+			var limitErr *LimitError
+			So(errors.As(err, &limitErr), ShouldBeTrue)
+			So(limitErr.FolderId, ShouldEqual, 37)
+			So(limitErr.VolumeId, ShouldEqual, 1)
+			So(limitErr.Depth, ShouldEqual, 3)
+			So(limitErr.TotalCount, ShouldEqual, 19)
+			So(limitErr.Max, ShouldEqual, 18)
+		})
+
+		Convey("TestFileDirLoad retrieve error", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			errRetrieve := fmt.Errorf("retrieve failed")
+			failRetrieve := func(ctx context.Context, volumeId, folderId int64) (files, folders []*File, err error) {
+				if folderId == 22 {
+					return nil, nil, errRetrieve
+				}
+				return getSubFilesMock(ctx, volumeId, folderId)
+			}
+			_, _, err := dir.DFSLoad(nil, -1, -1, -1, failRetrieve, nil, nil)
+			So(errors.Is(err, errRetrieve), ShouldBeTrue)
+			var dirErr *DirError
+			So(errors.As(err, &dirErr), ShouldBeTrue)
+			So(dirErr.FolderId, ShouldEqual, 22)
+			So(dirErr.VolumeId, ShouldEqual, 1)
+			So(dirErr.Depth, ShouldEqual, 2)
+		})
+
+		Convey("TestFileDirLoad ErrDirAlreadyLoaded", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			files, folders, _ := getSubFilesMock(nil, 1, 0)
+			So(dir.FillDirNoRecurse(nil, files, folders), ShouldBeNil)
+			err := dir.FillDirNoRecurse(nil, files, folders)
+			So(errors.Is(err, ErrDirAlreadyLoaded), ShouldBeTrue)
 		})
 
 	})
@@ -197,7 +234,155 @@ func TestGetTotalSizeAndCount(t *testing.T) {
 	})
 }
 
-func ExampleGetFoldersAndFiles() {
+func TestContextCancel(t *testing.T) {
+	Convey("TestContextCancel", t, func() {
+		Convey("TestContextCancel DFSLoad canceled before load", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, _, err := dir.DFSLoad(ctx, -1, -1, -1, getSubFilesMock, nil, nil)
+			So(err, ShouldEqual, context.Canceled)
+			So(dir.IsLoaded(), ShouldBeFalse)
+		})
+
+		Convey("TestContextCancel DFSLoad canceled while loading", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			ctx, cancel := context.WithCancel(context.Background())
+			var retrieved []int64
+			cancelRetrieve := func(ctx context.Context, volumeId, folderId int64) (files, folders []*File, err error) {
+				retrieved = append(retrieved, folderId)
+				if folderId == 12 {
+					cancel()
+				}
+				return getSubFilesMock(ctx, volumeId, folderId)
+			}
+			_, _, err := dir.DFSLoad(ctx, -1, -1, -1, cancelRetrieve, nil, nil)
+			So(err, ShouldEqual, context.Canceled)
+			So(retrieved, ShouldResemble, []int64{0, 12})
+		})
+
+		Convey("TestContextCancel DFSLoad WithTimeout", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			slowRetrieve := func(ctx context.Context, volumeId, folderId int64) (files, folders []*File, err error) {
+				time.Sleep(5 * time.Millisecond)
+				return getSubFilesMock(ctx, volumeId, folderId)
+			}
+			_, _, err := dir.DFSLoad(nil, -1, -1, -1, slowRetrieve, nil, nil, WithTimeout(12*time.Millisecond))
+			So(errors.Is(err, context.DeadlineExceeded), ShouldBeTrue)
+		})
+
+		Convey("TestContextCancel BFSLoad and ConcurrentLoad", func() {
+			buildTreeForTest()
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, _, err := newNewVirtualDirForTest().BFSLoad(ctx, -1, -1, -1, getSubFilesMock, nil, nil)
+			So(err, ShouldEqual, context.Canceled)
+			_, _, err = newNewVirtualDirForTest().ConcurrentLoad(ctx, 4, -1, -1, -1, getSubFilesMock, nil, nil)
+			So(err, ShouldEqual, context.Canceled)
+		})
+
+		Convey("TestContextCancel DfsWithFunc and BfsWithFunc", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			_, _, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil)
+			So(err, ShouldBeNil)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			var visited int
+			cancelFunc := func(ctx context.Context, dir *Dir) error {
+				visited++
+				cancel()
+				return nil
+			}
+			err = dir.DfsWithFunc(ctx, cancelFunc, nil)
+			So(err, ShouldEqual, context.Canceled)
+			So(visited, ShouldEqual, 1)
+
+			visited = 0
+			err = dir.BfsWithFunc(ctx, cancelFunc)
+			So(err, ShouldEqual, context.Canceled)
+			So(visited, ShouldEqual, 0)
+		})
+	})
+}
+
+//chainRetrieveForTest 模拟一条深度为n的目录链:folder i下面有一个文件和folder i+1
+func chainRetrieveForTest(n int64) RetrieveNextDepthFilesFunc {
+	return func(ctx context.Context, volumeId, folderId int64) (files, folders []*File, err error) {
+		files = []*File{{Id: n + folderId, ParentId: folderId, VolumeId: volumeId, Type: typeFile, Size: 1}}
+		if folderId < n {
+			folders = []*File{{Id: folderId + 1, ParentId: folderId, VolumeId: volumeId, Type: typeFolder}}
+		}
+		return files, folders, nil
+	}
+}
+
+func TestDeepTree(t *testing.T) {
+	Convey("TestDeepTree", t, func() {
+		const n = 20000
+		newChainRoot := func() *Dir {
+			return NewDir(&File{Id: 1, VolumeId: 1, Type: typeFolder}, 0, unKnown, unKnown)
+		}
+
+		Convey("TestDeepTree default max depth", func() {
+			_, _, err := newChainRoot().DFSLoad(nil, -1, -1, -1, chainRetrieveForTest(n), nil, nil)
+			So(errors.Is(err, ErrMaxPathDepthLimit), ShouldBeTrue)
+
+			SetDefaultMaxDepth(UnlimitedDepth)
+			defer SetDefaultMaxDepth(-1)
+			_, totalCount, err := newChainRoot().DFSLoad(nil, -1, -1, -1, chainRetrieveForTest(n), nil, nil)
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 2*n)
+		})
+
+		Convey("TestDeepTree DFSLoad and DfsWithFunc", func() {
+			root := newChainRoot()
+			var loadPre, loadPost []int64
+			totalSize, totalCount, err := root.DFSLoad(nil, UnlimitedDepth, -1, -1, chainRetrieveForTest(n),
+				func(ctx context.Context, dir *Dir) error {
+					loadPre = append(loadPre, dir.GetId())
+					return nil
+				},
+				func(ctx context.Context, dir *Dir) error {
+					loadPost = append(loadPost, dir.GetId())
+					return nil
+				})
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 2*n)
+			So(totalSize, ShouldEqual, n)
+			So(len(loadPre), ShouldEqual, n)
+			So(len(loadPost), ShouldEqual, n)
+			So(loadPre[0], ShouldEqual, 1)
+			So(loadPre[n-1], ShouldEqual, n)
+			So(loadPost[0], ShouldEqual, n)
+			So(loadPost[n-1], ShouldEqual, 1)
+
+			var pre, post []int64
+			err = root.DfsWithFunc(nil,
+				func(ctx context.Context, dir *Dir) error {
+					pre = append(pre, dir.GetId())
+					return nil
+				},
+				func(ctx context.Context, dir *Dir) error {
+					post = append(post, dir.GetId())
+					return nil
+				})
+			So(err, ShouldBeNil)
+			So(pre, ShouldResemble, loadPre)
+			So(post, ShouldResemble, loadPost)
+			So(len(root.GetAllPureFiles(nil)), ShouldEqual, n)
+
+			namePath, err := root.PathOf(nil, 2*n) //最深的文件,链上的名字都是空的
+			So(err, ShouldBeNil)
+			So(len(namePath), ShouldEqual, n)
+		})
+	})
+}
+
+func ExampleDir_GetAllFoldersAndFiles() {
 	buildTreeForTest()
 	dir := newNewVirtualDirForTest()
 	_, _, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil)
@@ -324,7 +509,7 @@ func ExampleGetFoldersAndFiles() {
 }
 
 // 遍历 "测试使用DoPreorderFunc和DoPostorderFunc遍历树"
-func ExampleTraverse() {
+func ExampleDir_DfsWithFunc() {
 	buildTreeForTest()
 	dir := newNewVirtualDirForTest()
 	_, _, err := dir.DFSLoad(nil, 10, -1, -1, getSubFilesMock, nil, nil)
@@ -413,7 +598,7 @@ func ExampleTraverse() {
 }
 
 // 遍历 "测试手动先增加一层目录信息"
-func ExampleManualLoadTraverse() {
+func ExampleDir_FillDirNoRecurse() {
 	buildTreeForTest()
 	dir := newNewVirtualDirForTest()
 	files, folders, _ := getSubFilesMock(nil, 1, 0)
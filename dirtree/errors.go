@@ -0,0 +1,141 @@
+package dirtree
+
+import (
+	"errors"
+	"fmt"
+)
+
+//可以用errors.Is判断的错误
+var (
+	ErrDirAlreadyLoaded             = errors.New("dir already loaded")
+	ErrDirNotLoad                   = errors.New("dir not load")
+	ErrNoRetrieveNextDepthFilesFunc = errors.New("no RetrieveNextDepthFilesFunc")
+	ErrNotFolderType                = errors.New("not folder type")
+	ErrMaxPathDepthLimit            = errors.New("max path depth limit")
+	ErrFileNumLimit                 = errors.New("file num limit")
+	ErrTotalSizeLimit               = errors.New("total size limit")
+	ErrRootNotFound                 = errors.New("root folder not found")
+	ErrNoContentFunc                = errors.New("no ContentFunc")
+	ErrNotSameTree                  = errors.New("not in the same tree")
+	ErrNodeNotFound                 = errors.New("node not found")
+	ErrDuplicateId                  = errors.New("duplicate id")
+	ErrMoveIntoSelf                 = errors.New("move folder into itself or its descendant")
+	ErrVolumeMismatch               = errors.New("volume id mismatch")
+	ErrModifyRoot                   = errors.New("cannot remove or move tree root")
+	ErrNilEventFile                 = errors.New("event without file")
+	ErrTooManyPending               = errors.New("too many pending events")
+	ErrParentDeleted                = errors.New("parent folder deleted")
+	ErrInvalidTree                  = errors.New("invalid tree")
+	ErrRepeatedCursor               = errors.New("page cursor repeated")
+)
+
+//DirError 操作某个dir时出错,Err是具体的错误(包括RetrieveNextDepthFilesFunc返回的错误)
+type DirError struct {
+	Op       string //出错的操作,如retrieve,fill,traverse
+	VolumeId int64
+	FolderId int64
+	Depth    int64
+	Err      error
+}
+
+func newDirError(op string, dir *Dir, err error) *DirError {
+	return &DirError{
+		Op:       op,
+		VolumeId: dir.originInfo.VolumeId,
+		FolderId: dir.originInfo.Id,
+		Depth:    dir.depth,
+		Err:      err,
+	}
+}
+
+func (e *DirError) Error() string {
+	return fmt.Sprintf("dirtree %s volumeId=%d,folderId=%d,depth=%d: %v", e.Op, e.VolumeId, e.FolderId, e.Depth, e.Err)
+}
+
+func (e *DirError) Unwrap() error {
+	return e.Err
+}
+
+//LimitError 加载时触发限制的错误,errors.Is可以判断是ErrMaxPathDepthLimit,ErrFileNumLimit还是ErrTotalSizeLimit
+type LimitError struct {
+	Limit      error //ErrMaxPathDepthLimit,ErrFileNumLimit或ErrTotalSizeLimit
+	Max        int64 //限制的值,即maxDepth,numLimit或sizeLimit
+	VolumeId   int64
+	FolderId   int64 //触发限制的folder
+	Depth      int64
+	TotalCount int64 //触发限制时已加载的文件(夹)总数
+	TotalSize  int64 //触发限制时已加载的文件总大小
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("dirtree %v volumeId=%d,folderId=%d,depth=%d,totalCount=%d,totalSize=%d,max=%d",
+		e.Limit, e.VolumeId, e.FolderId, e.Depth, e.TotalCount, e.TotalSize, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Limit
+}
+
+//WalkErrors 收集模式下遍历出现的所有错误,每个都是*DirError,errors.Is和errors.As会逐个检查
+type WalkErrors struct {
+	Errs []error
+}
+
+func (e *WalkErrors) Error() string {
+	if len(e.Errs) == 1 {
+		return e.Errs[0].Error()
+	}
+	return fmt.Sprintf("%v (and %d more errors)", e.Errs[0], len(e.Errs)-1)
+}
+
+func (e *WalkErrors) Is(target error) bool {
+	for _, err := range e.Errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *WalkErrors) As(target any) bool {
+	for _, err := range e.Errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+//EventError EventApplier没有应用成功的事件以及原因
+type EventError struct {
+	Event *Event
+	Err   error
+}
+
+func (e *EventError) Error() string {
+	if e.Event.File == nil {
+		return fmt.Sprintf("dirtree apply %v: %v", e.Event.Type, e.Err)
+	}
+	return fmt.Sprintf("dirtree apply %v id=%d,parentId=%d,version=%d: %v",
+		e.Event.Type, e.Event.File.Id, e.Event.File.ParentId, e.Event.File.Version, e.Err)
+}
+
+func (e *EventError) Unwrap() error {
+	return e.Err
+}
+
+//ValidationError WithValidation加载后检查发现的问题,errors.Is可以判断ErrInvalidTree
+type ValidationError struct {
+	Violations []*Violation
+}
+
+func (e *ValidationError) Error() string {
+	if len(e.Violations) == 1 {
+		return fmt.Sprintf("dirtree %v: %v", ErrInvalidTree, e.Violations[0])
+	}
+	return fmt.Sprintf("dirtree %v: %v (and %d more violations)", ErrInvalidTree, e.Violations[0], len(e.Violations)-1)
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalidTree
+}
@@ -0,0 +1,303 @@
+package dirtree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+const defMaxPending = 10000 //EventApplier默认最多缓冲的事件数量
+
+//EventType 变更事件的类型
+type EventType int
+
+const (
+	EventCreate EventType = iota + 1
+	EventUpdate
+	EventDelete
+	EventMove
+)
+
+var eventType2Str = map[EventType]string{
+	EventCreate: "create",
+	EventUpdate: "update",
+	EventDelete: "delete",
+	EventMove:   "move",
+}
+
+func (t EventType) String() string {
+	if s, ok := eventType2Str[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+//Event 后端推送的变更事件,File是变更之后节点的完整信息,按File.Id和File.ParentId定位,EventDelete只需要Id和Version
+type Event struct {
+	Type EventType
+	File *File
+}
+
+//ApplyResult 一次Apply的结果
+type ApplyResult struct {
+	Applied []*Event      //已经应用的事件,包括之前缓冲,这次才应用的事件
+	Ignored []*Event      //Version不比树上的新,过期或者重复的事件
+	Pending []*Event      //这次新缓冲的事件,父目录还不在树上或者还没有加载
+	Failed  []*EventError //没有办法应用的事件
+}
+
+/*
+	EventApplier
+	把变更事件应用到已加载的目录树上,不需要重新加载整个volume。
+	1.按Version去重:事件的Version不大于树上节点(或者已删除节点)的Version时忽略;
+	2.事件的目标父目录(File.ParentId)还不在树上或者还没有加载时先缓冲,
+	  之后父目录被创建,移入或者加载(下一次Apply时检查)后再按原来的顺序应用;
+	3.create和move会在需要时新增节点:create出来的文件夹是已加载的空目录,
+	  从树外移入的文件夹内容未知,是未加载状态;update和create遇到已存在的节点时更新属性,ParentId变了同时移动;
+	4.删除文件夹时,缓冲在这个子树里面的事件都作为失败返回(ErrParentDeleted);
+	5.每个删除事件都会在内存里保留一条记录(id和Version),直到同一个id重新创建或者调用PruneDeleted,
+	  长时间运行时应该按后端已经确认的事件水位定期调用PruneDeleted,否则记录会一直增长。
+	开启了id索引(EnableIndex)时每个事件都是O(1)查找,否则需要遍历整棵树。
+	注意:EventApplier不是并发安全的。
+*/
+type EventApplier struct {
+	root       *Dir
+	maxPending int
+	deleted    map[int64]int64    //已删除节点最后的Version,用来忽略过期的事件
+	pending    map[int64][]*Event //按ParentId缓冲的事件
+	pendingNum int
+}
+
+//NewEventApplier 新建EventApplier,maxPending是最多缓冲的事件数量,<=0时使用默认值,超过之后的事件返回ErrTooManyPending
+func NewEventApplier(root *Dir, maxPending int) *EventApplier {
+	if maxPending <= 0 {
+		maxPending = defMaxPending
+	}
+	return &EventApplier{
+		root:       root,
+		maxPending: maxPending,
+		deleted:    make(map[int64]int64),
+		pending:    make(map[int64][]*Event),
+	}
+}
+
+//Apply 按顺序应用事件,先重试父目录已经可用的缓冲事件。只有ctx取消时返回错误,已经应用的结果仍然有效
+func (a *EventApplier) Apply(ctx context.Context, events ...*Event) (*ApplyResult, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	result := &ApplyResult{}
+	a.flush(ctx, result)
+	for _, e := range events {
+		if err := ctxErr(ctx); err != nil {
+			return result, err
+		}
+		a.apply(ctx, e, result)
+	}
+	return result, nil
+}
+
+//PendingCount 缓冲中的事件数量
+func (a *EventApplier) PendingCount() int {
+	return a.pendingNum
+}
+
+//Pending 缓冲中的事件,按ParentId从小到大,同一个ParentId按到达顺序
+func (a *EventApplier) Pending() []*Event {
+	var events []*Event
+	for _, parentId := range a.pendingParentIds() {
+		events = append(events, a.pending[parentId]...)
+	}
+	return events
+}
+
+//DeletedCount 记录中的已删除节点数量
+func (a *EventApplier) DeletedCount() int {
+	return len(a.deleted)
+}
+
+//PruneDeleted 清理Version不大于version的已删除记录,返回清理的数量。
+//清理之后这些id的过期事件不再被忽略,父目录是这些id的事件会被缓冲而不是返回ErrParentDeleted,
+//所以只有确认不会再收到Version不大于version的事件时才可以调用
+func (a *EventApplier) PruneDeleted(version int64) int {
+	pruned := 0
+	for id, deletedVersion := range a.deleted {
+		if deletedVersion <= version {
+			delete(a.deleted, id)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+func (a *EventApplier) apply(ctx context.Context, e *Event, result *ApplyResult) {
+	if e.File == nil {
+		a.fail(e, ErrNilEventFile, result)
+		return
+	}
+	id := e.File.Id
+	file, dir, parent, err := a.root.findNode(ctx, "apply", id)
+	if err != nil && !errors.Is(err, ErrNodeNotFound) {
+		a.fail(e, err, result)
+		return
+	}
+	exists := err == nil
+	if exists && e.File.Version <= file.Version {
+		result.Ignored = append(result.Ignored, e)
+		return
+	}
+	if version, ok := a.deleted[id]; !exists && ok && e.File.Version <= version {
+		result.Ignored = append(result.Ignored, e)
+		return
+	}
+
+	if e.Type == EventDelete {
+		a.applyDelete(ctx, e, dir, exists, result)
+		return
+	}
+	if exists && dir == a.root { //根节点只更新属性
+		a.done(ctx, e, a.root.UpdateNode(ctx, id, e.File), result)
+		return
+	}
+	target, ok, err := a.loadedDir(ctx, e.File.ParentId)
+	if err != nil {
+		a.fail(e, err, result)
+		return
+	}
+	if !ok {
+		a.buffer(e, result)
+		return
+	}
+	if exists {
+		if parent != target {
+			if err = a.root.MoveNode(ctx, id, target.originInfo.Id); err != nil {
+				a.fail(e, err, result)
+				return
+			}
+		}
+		a.done(ctx, e, a.root.UpdateNode(ctx, id, e.File), result)
+		return
+	}
+	if e.Type == EventUpdate {
+		a.fail(e, fmt.Errorf("dirtree apply id=%d: %w", id, ErrNodeNotFound), result)
+		return
+	}
+	newFile := *e.File
+	a.done(ctx, e, a.root.addNode(ctx, target.originInfo.Id, &newFile, e.Type == EventCreate), result)
+}
+
+//applyDelete 删除节点,不在树上的节点只记录Version,缓冲在被删除的子树里面的事件都失败
+func (a *EventApplier) applyDelete(ctx context.Context, e *Event, dir *Dir, exists bool, result *ApplyResult) {
+	folderIds := []int64{e.File.Id}
+	if exists {
+		if dir != nil {
+			folderIds = folderIds[:0]
+			_ = dir.dfsStack(func(subDir *Dir) error {
+				folderIds = append(folderIds, subDir.originInfo.Id)
+				return nil
+			}, nil)
+		}
+		if _, err := a.root.RemoveNode(ctx, e.File.Id); err != nil {
+			a.fail(e, err, result)
+			return
+		}
+	}
+	for _, folderId := range folderIds {
+		a.dropPending(folderId, result)
+	}
+	a.deleted[e.File.Id] = e.File.Version
+	result.Applied = append(result.Applied, e)
+}
+
+//done 记录应用的结果,成功应用的文件夹会重试缓冲在它下面的事件
+func (a *EventApplier) done(ctx context.Context, e *Event, err error, result *ApplyResult) {
+	if err != nil {
+		a.fail(e, err, result)
+		return
+	}
+	delete(a.deleted, e.File.Id)
+	result.Applied = append(result.Applied, e)
+	if e.File.IsFolder() {
+		if dir, ok, _ := a.loadedDir(ctx, e.File.Id); ok {
+			a.retry(ctx, dir.originInfo.Id, result)
+		}
+	}
+}
+
+func (a *EventApplier) fail(e *Event, err error, result *ApplyResult) {
+	result.Failed = append(result.Failed, &EventError{Event: e, Err: err})
+}
+
+//loadedDir 查找已经加载的文件夹,不在树上或者没有加载时ok为false,id对应的是文件时返回ErrNotFolderType,
+//查找时的其它错误(比如ctx取消)直接返回,不能当作父目录还不可用
+func (a *EventApplier) loadedDir(ctx context.Context, id int64) (dir *Dir, ok bool, err error) {
+	_, dir, _, err = a.root.findNode(ctx, "apply", id)
+	if errors.Is(err, ErrNodeNotFound) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if dir == nil {
+		return nil, false, fmt.Errorf("dirtree apply id=%d: %w", id, ErrNotFolderType)
+	}
+	return dir, dir.loaded, nil
+}
+
+//buffer 缓冲父目录还不可用的事件
+func (a *EventApplier) buffer(e *Event, result *ApplyResult) {
+	parentId := e.File.ParentId
+	if _, ok := a.deleted[parentId]; ok {
+		a.fail(e, ErrParentDeleted, result)
+		return
+	}
+	if a.pendingNum >= a.maxPending {
+		a.fail(e, ErrTooManyPending, result)
+		return
+	}
+	a.pending[parentId] = append(a.pending[parentId], e)
+	a.pendingNum++
+	result.Pending = append(result.Pending, e)
+}
+
+//retry 按原来的顺序重新应用缓冲在parentId下面的事件
+func (a *EventApplier) retry(ctx context.Context, parentId int64, result *ApplyResult) {
+	events := a.pending[parentId]
+	if len(events) == 0 {
+		return
+	}
+	delete(a.pending, parentId)
+	a.pendingNum -= len(events)
+	for _, e := range events {
+		a.apply(ctx, e, result)
+	}
+}
+
+//flush 重试父目录已经可用(比如被外部加载)的缓冲事件
+func (a *EventApplier) flush(ctx context.Context, result *ApplyResult) {
+	for _, parentId := range a.pendingParentIds() {
+		if _, ok, _ := a.loadedDir(ctx, parentId); ok {
+			a.retry(ctx, parentId, result)
+		}
+	}
+}
+
+//dropPending 父目录被删除,缓冲在它下面的事件都失败
+func (a *EventApplier) dropPending(parentId int64, result *ApplyResult) {
+	events := a.pending[parentId]
+	delete(a.pending, parentId)
+	a.pendingNum -= len(events)
+	for _, e := range events {
+		a.fail(e, ErrParentDeleted, result)
+	}
+}
+
+func (a *EventApplier) pendingParentIds() []int64 {
+	parentIds := make([]int64, 0, len(a.pending))
+	for parentId := range a.pending {
+		parentIds = append(parentIds, parentId)
+	}
+	sort.Slice(parentIds, func(i, j int) bool { return parentIds[i] < parentIds[j] })
+	return parentIds
+}
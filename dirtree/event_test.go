@@ -0,0 +1,225 @@
+package dirtree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func eventIds(events []*Event) []int64 {
+	var ids []int64
+	for _, e := range events {
+		ids = append(ids, e.File.Id)
+	}
+	return ids
+}
+
+//countCtx Err被调用limit次之后返回context.Canceled
+type countCtx struct {
+	context.Context
+	calls, limit int
+}
+
+func (c *countCtx) Err() error {
+	c.calls++
+	if c.limit > 0 && c.calls > c.limit {
+		return context.Canceled
+	}
+	return nil
+}
+
+func TestEventApplier(t *testing.T) {
+	Convey("TestEventApplier", t, func() {
+		for _, indexed := range []bool{false, true} {
+			indexed := indexed
+			Convey(fmt.Sprintf("TestEventApplier indexed=%v", indexed), func() {
+				buildTreeForTest()
+				dir := newNewVirtualDirForTest()
+				if indexed {
+					dir.EnableIndex()
+				}
+				_, _, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil)
+				So(err, ShouldBeNil)
+				dir22 := dir.GetSubDirs()[0].GetSubDirs()[0]
+				dir24 := dir.GetSubDirs()[1].GetSubDirs()[0]
+				applier := NewEventApplier(dir, 0)
+
+				Convey("TestEventApplier create update move delete", func() {
+					result, err := applier.Apply(nil,
+						&Event{Type: EventCreate, File: &File{Id: 50, ParentId: 22, VolumeId: 1, Type: typeFile, Version: 1, Size: 5}},
+						&Event{Type: EventUpdate, File: &File{Id: 50, ParentId: 22, VolumeId: 1, Type: typeFile, Version: 2, Size: 7, Name: "x"}},
+						&Event{Type: EventUpdate, File: &File{Id: 50, ParentId: 22, VolumeId: 1, Type: typeFile, Version: 2, Size: 9}},
+						&Event{Type: EventUpdate, File: &File{Id: 11, ParentId: 0, VolumeId: 1, Type: typeFile, Version: 1, Size: 9}},
+					)
+					So(err, ShouldBeNil)
+					So(eventIds(result.Applied), ShouldResemble, []int64{50, 50})
+					So(eventIds(result.Ignored), ShouldResemble, []int64{50, 11})
+					So(result.Failed, ShouldBeEmpty)
+					So(dir22.GetCount(), ShouldEqual, 5)
+					So(dir22.GetSize(), ShouldEqual, 10)
+					So(dir.GetTotalSize(), ShouldEqual, 17)
+					file, _, _, err := dir.findNode(nil, "test", 50)
+					So(err, ShouldBeNil)
+					So(file.Name, ShouldEqual, "x")
+
+					result, err = applier.Apply(nil,
+						&Event{Type: EventMove, File: &File{Id: 50, ParentId: 24, VolumeId: 1, Type: typeFile, Version: 3, Size: 7}},
+						&Event{Type: EventDelete, File: &File{Id: 50, Version: 4}},
+						&Event{Type: EventCreate, File: &File{Id: 50, ParentId: 22, VolumeId: 1, Type: typeFile, Version: 3}},
+						&Event{Type: EventDelete, File: &File{Id: 50, Version: 4}},
+					)
+					So(err, ShouldBeNil)
+					So(eventIds(result.Applied), ShouldResemble, []int64{50, 50})
+					So(eventIds(result.Ignored), ShouldResemble, []int64{50, 50})
+					So(dir22.GetCount(), ShouldEqual, 4)
+					So(dir24.GetCount(), ShouldEqual, 1)
+					So(dir.GetTotalSize(), ShouldEqual, 10)
+
+					result, err = applier.Apply(nil,
+						&Event{Type: EventCreate, File: &File{Id: 50, ParentId: 24, VolumeId: 1, Type: typeFile, Version: 5, Size: 1}},
+						&Event{Type: EventUpdate, File: &File{Id: 99, ParentId: 22, VolumeId: 1, Type: typeFile, Version: 1}},
+						&Event{Type: EventCreate, File: &File{Id: 98, ParentId: 41, VolumeId: 1, Type: typeFile, Version: 1}},
+						&Event{Type: EventUpdate},
+					)
+					So(err, ShouldBeNil)
+					So(eventIds(result.Applied), ShouldResemble, []int64{50})
+					So(dir24.GetCount(), ShouldEqual, 2)
+					So(len(result.Failed), ShouldEqual, 3)
+					So(errors.Is(result.Failed[0], ErrNodeNotFound), ShouldBeTrue)
+					So(errors.Is(result.Failed[1], ErrNotFolderType), ShouldBeTrue)
+					So(result.Failed[1].Event.File.Id, ShouldEqual, 98)
+					So(errors.Is(result.Failed[2], ErrNilEventFile), ShouldBeTrue)
+				})
+
+				Convey("TestEventApplier buffer until parent created", func() {
+					result, err := applier.Apply(nil,
+						&Event{Type: EventCreate, File: &File{Id: 71, ParentId: 70, VolumeId: 1, Type: typeFile, Version: 1, Size: 1}},
+						&Event{Type: EventCreate, File: &File{Id: 72, ParentId: 70, VolumeId: 1, Type: typeFolder, Version: 1}},
+						&Event{Type: EventCreate, File: &File{Id: 73, ParentId: 72, VolumeId: 1, Type: typeFile, Version: 1, Size: 2}},
+					)
+					So(err, ShouldBeNil)
+					So(eventIds(result.Pending), ShouldResemble, []int64{71, 72, 73})
+					So(applier.PendingCount(), ShouldEqual, 3)
+					So(eventIds(applier.Pending()), ShouldResemble, []int64{71, 72, 73})
+
+					result, err = applier.Apply(nil,
+						&Event{Type: EventCreate, File: &File{Id: 70, ParentId: 23, VolumeId: 1, Type: typeFolder, Version: 1}},
+					)
+					So(err, ShouldBeNil)
+					So(eventIds(result.Applied), ShouldResemble, []int64{70, 71, 72, 73})
+					So(applier.PendingCount(), ShouldEqual, 0)
+					So(dir.GetTotalSize(), ShouldEqual, 13)
+					So(dir.GetTotalFolderCount(), ShouldEqual, 11)
+				})
+
+				Convey("TestEventApplier buffer until parent loaded", func() {
+					result, err := applier.Apply(nil,
+						&Event{Type: EventMove, File: &File{Id: 60, ParentId: 22, VolumeId: 1, Type: typeFolder, Version: 1}},
+						&Event{Type: EventCreate, File: &File{Id: 61, ParentId: 60, VolumeId: 1, Type: typeFile, Version: 1}},
+					)
+					So(err, ShouldBeNil)
+					So(eventIds(result.Applied), ShouldResemble, []int64{60})
+					So(eventIds(result.Pending), ShouldResemble, []int64{61})
+					_, dir60, _, err := dir.findNode(nil, "test", 60)
+					So(err, ShouldBeNil)
+					So(dir60.IsLoaded(), ShouldBeFalse)
+
+					So(dir60.FillDirNoRecurse(nil, nil, nil), ShouldBeNil)
+					result, err = applier.Apply(nil)
+					So(err, ShouldBeNil)
+					So(eventIds(result.Applied), ShouldResemble, []int64{61})
+					So(dir60.GetCount(), ShouldEqual, 1)
+				})
+
+				Convey("TestEventApplier parent deleted", func() {
+					result, err := applier.Apply(nil,
+						&Event{Type: EventMove, File: &File{Id: 60, ParentId: 33, VolumeId: 1, Type: typeFolder, Version: 1}},
+						&Event{Type: EventCreate, File: &File{Id: 61, ParentId: 60, VolumeId: 1, Type: typeFile, Version: 1}},
+						&Event{Type: EventCreate, File: &File{Id: 81, ParentId: 80, VolumeId: 1, Type: typeFile, Version: 1}},
+						&Event{Type: EventDelete, File: &File{Id: 22, Version: 2}},
+						&Event{Type: EventDelete, File: &File{Id: 80, Version: 1}},
+						&Event{Type: EventCreate, File: &File{Id: 82, ParentId: 22, VolumeId: 1, Type: typeFile, Version: 1}},
+					)
+					So(err, ShouldBeNil)
+					So(eventIds(result.Applied), ShouldResemble, []int64{60, 22, 80})
+					So(len(result.Failed), ShouldEqual, 3)
+					So(eventIds([]*Event{result.Failed[0].Event, result.Failed[1].Event, result.Failed[2].Event}), ShouldResemble, []int64{61, 81, 82})
+					for _, failed := range result.Failed {
+						So(errors.Is(failed, ErrParentDeleted), ShouldBeTrue)
+					}
+					So(applier.PendingCount(), ShouldEqual, 0)
+					So(dir.GetTotalFolderCount(), ShouldEqual, 7)
+				})
+
+				Convey("TestEventApplier prune deleted", func() {
+					result, err := applier.Apply(nil,
+						&Event{Type: EventDelete, File: &File{Id: 10, Version: 2}},
+						&Event{Type: EventDelete, File: &File{Id: 80, Version: 5}},
+						&Event{Type: EventCreate, File: &File{Id: 10, ParentId: 0, VolumeId: 1, Type: typeFile, Version: 1}},
+					)
+					So(err, ShouldBeNil)
+					So(eventIds(result.Applied), ShouldResemble, []int64{10, 80})
+					So(eventIds(result.Ignored), ShouldResemble, []int64{10})
+					So(applier.DeletedCount(), ShouldEqual, 2)
+
+					So(applier.PruneDeleted(1), ShouldEqual, 0)
+					So(applier.PruneDeleted(2), ShouldEqual, 1)
+					So(applier.DeletedCount(), ShouldEqual, 1)
+					result, err = applier.Apply(nil,
+						&Event{Type: EventCreate, File: &File{Id: 81, ParentId: 80, VolumeId: 1, Type: typeFile, Version: 1}},
+					)
+					So(err, ShouldBeNil)
+					So(errors.Is(result.Failed[0], ErrParentDeleted), ShouldBeTrue)
+
+					So(applier.PruneDeleted(5), ShouldEqual, 1)
+					So(applier.DeletedCount(), ShouldEqual, 0)
+					result, err = applier.Apply(nil,
+						&Event{Type: EventCreate, File: &File{Id: 81, ParentId: 80, VolumeId: 1, Type: typeFile, Version: 1}},
+					)
+					So(err, ShouldBeNil)
+					So(eventIds(result.Pending), ShouldResemble, []int64{81})
+				})
+
+				Convey("TestEventApplier canceled while looking up parent", func() {
+					event := &Event{Type: EventCreate, File: &File{Id: 50, ParentId: 22, VolumeId: 1, Type: typeFile, Version: 1}}
+					counter := &countCtx{Context: context.Background()}
+					_, _, _, err := dir.findNode(counter, "test", 50)
+					So(errors.Is(err, ErrNodeNotFound), ShouldBeTrue)
+
+					//Apply检查一次ctx,查找事件节点之后,查找父目录时取消
+					ctx := &countCtx{Context: context.Background(), limit: 1 + counter.calls}
+					result, err := applier.Apply(ctx, event)
+					So(err, ShouldBeNil)
+					if indexed { //有索引时查找不检查ctx
+						So(eventIds(result.Applied), ShouldResemble, []int64{50})
+					} else {
+						So(result.Pending, ShouldBeEmpty)
+						So(len(result.Failed), ShouldEqual, 1)
+						So(errors.Is(result.Failed[0], context.Canceled), ShouldBeTrue)
+					}
+				})
+
+				Convey("TestEventApplier too many pending and canceled", func() {
+					applier = NewEventApplier(dir, 1)
+					result, err := applier.Apply(nil,
+						&Event{Type: EventCreate, File: &File{Id: 71, ParentId: 70, VolumeId: 1, Type: typeFile, Version: 1}},
+						&Event{Type: EventCreate, File: &File{Id: 72, ParentId: 70, VolumeId: 1, Type: typeFile, Version: 1}},
+					)
+					So(err, ShouldBeNil)
+					So(eventIds(result.Pending), ShouldResemble, []int64{71})
+					So(len(result.Failed), ShouldEqual, 1)
+					So(errors.Is(result.Failed[0], ErrTooManyPending), ShouldBeTrue)
+
+					ctx, cancel := context.WithCancel(context.Background())
+					cancel()
+					_, err = applier.Apply(ctx, &Event{Type: EventDelete, File: &File{Id: 10, Version: 2}})
+					So(err, ShouldEqual, context.Canceled)
+					So(dir.GetCount(), ShouldEqual, 4)
+				})
+			})
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package dirtree
+
+import (
+	"context"
+)
+
+//FileFunc 处理单个子文件(夹)条目的func,depth是file在目录树中的层级(parent.depth+1),parent是file所在的dir
+type FileFunc func(ctx context.Context, file *File, depth int64, parent *Dir) error
+
+/*
+	DfsWithFileFunc
+	dfs遍历,同时对每个子文件(夹)条目调用fileFunc,调用时需要已经load数据。
+	对每个dir的调用顺序:
+	1.preorderFunc(dir);
+	2.对dir的每个子文件夹条目调用fileFunc,然后对每个子文件条目调用fileFunc(和GetSubFoldersAndFiles顺序一致);
+	3.按顺序递归遍历子目录;
+	4.postorderFunc(dir)。
+	所以fileFunc的调用顺序和GetAllFoldersAndFiles返回的顺序一致,根节点本身不会作为条目传给fileFunc。
+	fileFunc返回SkipDir时:如果是文件夹条目,不再遍历这个子目录(子目录没有load也不会返回ErrDirNotLoad);如果是文件条目,跳过dir剩余的文件条目,
+	并且不再遍历dir的所有子目录,dir的postorderFunc照常调用。返回SkipAll时停止遍历并返回nil。
+	preorderFunc,postorderFunc返回值的含义和DfsWithFunc一样,三个func都可以为nil。
+*/
+func (d *Dir) DfsWithFileFunc(ctx context.Context, preorderFunc DirFunc, fileFunc FileFunc, postorderFunc DirFunc) error {
+	if fileFunc == nil {
+		return d.DfsWithFunc(ctx, preorderFunc, postorderFunc)
+	}
+	skipped := make(map[*Dir]bool) //fileFunc返回SkipDir而不需要遍历的dir,可以是没有load的dir
+	return d.dfsWithFunc(ctx, func(ctx context.Context, dir *Dir) error {
+		if preorderFunc != nil {
+			if err := preorderFunc(ctx, dir); err != nil {
+				return err
+			}
+		}
+		return dir.visitEntries(ctx, fileFunc, skipped)
+	}, postorderFunc, skipped)
+}
+
+/*
+	BfsWithFileFunc
+	Bfs遍历,同时对每个子文件(夹)条目调用fileFunc,调用时需要已经load数据。
+	对每个dir先调用callBack(dir),再按GetSubFoldersAndFiles的顺序对它的子文件(夹)条目调用fileFunc,
+	然后才处理队列里的下一个dir,所以fileFunc的调用顺序和GetAllFoldersAndFilesByBfs返回的顺序一致。
+	callBack和fileFunc返回SkipDir,SkipAll的含义和DfsWithFileFunc一样,都可以为nil。
+*/
+func (d *Dir) BfsWithFileFunc(ctx context.Context, callBack DirFunc, fileFunc FileFunc) error {
+	if fileFunc == nil {
+		return d.BfsWithFunc(ctx, callBack)
+	}
+	skipped := make(map[*Dir]bool)
+	return d.BfsWithFunc(ctx, func(ctx context.Context, dir *Dir) error {
+		if skipped[dir] {
+			delete(skipped, dir)
+			return SkipDir
+		}
+		if callBack != nil {
+			if err := callBack(ctx, dir); err != nil {
+				return err
+			}
+		}
+		return dir.visitEntries(ctx, fileFunc, skipped)
+	})
+}
+
+//visitEntries 先对子文件夹条目再对子文件条目调用fileFunc,fileFunc返回SkipDir时把不需要遍历的子目录放入skipped
+func (d *Dir) visitEntries(ctx context.Context, fileFunc FileFunc, skipped map[*Dir]bool) error {
+	depth := d.depth + 1
+	for _, subDir := range d.subDirs {
+		if err := ctxErr(ctx); err != nil {
+			return err
+		}
+		if err := fileFunc(ctx, subDir.originInfo, depth, d); err != nil {
+			if err != SkipDir {
+				return err
+			}
+			skipped[subDir] = true
+		}
+	}
+	for _, file := range d.subFiles {
+		if err := ctxErr(ctx); err != nil {
+			return err
+		}
+		if err := fileFunc(ctx, file, depth, d); err != nil {
+			if err != SkipDir {
+				return err
+			}
+			for _, subDir := range d.subDirs {
+				skipped[subDir] = true
+			}
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package dirtree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+//collectFileFunc 按调用顺序记录file的id,遇到id时返回ret
+func collectFileFunc(id int64, ret error, ids *[]int64) FileFunc {
+	return func(ctx context.Context, file *File, depth int64, parent *Dir) error {
+		*ids = append(*ids, file.Id)
+		if file.Id == id {
+			return ret
+		}
+		return nil
+	}
+}
+
+func TestFileFunc(t *testing.T) {
+	Convey("TestFileFunc", t, func() {
+		buildTreeForTest()
+		dir := newNewVirtualDirForTest()
+		_, _, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil)
+		So(err, ShouldBeNil)
+
+		Convey("TestFileFunc DfsWithFileFunc order", func() {
+			var ids []int64
+			So(dir.DfsWithFileFunc(nil, nil, collectFileFunc(-1, nil, &ids), nil), ShouldBeNil)
+			So(ids, ShouldResemble, fileIds(dir.GetAllFoldersAndFiles(nil)))
+
+			var events []string
+			dir22 := dir.GetSubDirs()[0].GetSubDirs()[0]
+			err := dir22.DfsWithFileFunc(nil,
+				func(ctx context.Context, dir *Dir) error {
+					events = append(events, fmt.Sprintf("pre:%v", dir.GetId()))
+					return nil
+				},
+				func(ctx context.Context, file *File, depth int64, parent *Dir) error {
+					So(file.ParentId, ShouldEqual, parent.GetId())
+					events = append(events, fmt.Sprintf("file:%v@%v", file.Id, depth))
+					return nil
+				},
+				func(ctx context.Context, dir *Dir) error {
+					events = append(events, fmt.Sprintf("post:%v", dir.GetId()))
+					return nil
+				})
+			So(err, ShouldBeNil)
+			So(events, ShouldResemble, []string{"pre:22", "file:33@3", "file:30@3", "file:31@3", "file:32@3",
+				"pre:33", "file:41@4", "post:33", "post:22"})
+		})
+
+		Convey("TestFileFunc DfsWithFileFunc skip", func() {
+			var ids []int64
+			So(dir.DfsWithFileFunc(nil, nil, collectFileFunc(22, SkipDir, &ids), nil), ShouldBeNil)
+			So(ids, ShouldResemble, []int64{12, 13, 10, 11, 22, 23, 20, 21, 35, 36, 34, 24, 37, 42})
+
+			ids = nil
+			var postIds []int64
+			So(dir.DfsWithFileFunc(nil, nil, collectFileFunc(20, SkipDir, &ids), skipDirFunc(-1, nil, &postIds)), ShouldBeNil)
+			So(ids, ShouldResemble, []int64{12, 13, 10, 11, 22, 23, 20, 24, 37, 42})
+			So(postIds, ShouldResemble, []int64{12, 37, 24, 13, 0})
+
+			ids = nil
+			So(dir.DfsWithFileFunc(nil, nil, collectFileFunc(33, SkipAll, &ids), nil), ShouldBeNil)
+			So(ids, ShouldResemble, []int64{12, 13, 10, 11, 22, 23, 20, 21, 33})
+		})
+
+		Convey("TestFileFunc DfsWithFileFunc skip unloaded dir", func() {
+			dir := unloadedDirForTest()
+			var ids []int64
+			So(dir.DfsWithFileFunc(nil, nil, collectFileFunc(22, SkipDir, &ids), nil), ShouldBeNil)
+			So(ids, ShouldResemble, []int64{12, 13, 10, 11, 22, 23, 20, 21, 35, 36, 34, 24, 37, 42})
+
+			ids = nil
+			err := dir.DfsWithFileFunc(nil, nil, collectFileFunc(-1, nil, &ids), nil)
+			So(errors.Is(err, ErrDirNotLoad), ShouldBeTrue)
+		})
+
+		Convey("TestFileFunc BfsWithFileFunc", func() {
+			var ids []int64
+			So(dir.BfsWithFileFunc(nil, nil, collectFileFunc(-1, nil, &ids)), ShouldBeNil)
+			So(ids, ShouldResemble, fileIds(dir.GetAllFoldersAndFilesByBfs(nil)))
+
+			ids = nil
+			So(dir.BfsWithFileFunc(nil, nil, collectFileFunc(12, SkipDir, &ids)), ShouldBeNil)
+			So(ids, ShouldResemble, []int64{12, 13, 10, 11, 24, 37, 42})
+
+			ids = nil
+			So(dir.BfsWithFileFunc(nil, nil, collectFileFunc(24, SkipAll, &ids)), ShouldBeNil)
+			So(ids, ShouldResemble, []int64{12, 13, 10, 11, 22, 23, 20, 21, 24})
+		})
+
+		Convey("TestFileFunc error", func() {
+			errStop := fmt.Errorf("stop")
+			var ids []int64
+			So(dir.DfsWithFileFunc(nil, nil, collectFileFunc(23, errStop, &ids), nil), ShouldEqual, errStop)
+			So(dir.BfsWithFileFunc(nil, nil, collectFileFunc(23, errStop, &ids)), ShouldEqual, errStop)
+		})
+	})
+}
@@ -0,0 +1,130 @@
+package dirtree
+
+import (
+	"context"
+	"fmt"
+)
+
+//BuildReport 从平铺列表构建目录树时发现的问题,有问题的文件(夹)不会出现在目录树里(VolumeMismatches除外)
+type BuildReport struct {
+	Orphans          []*File //找不到父目录(或父节点不是文件夹)的文件(夹),包括挂在它们下面的
+	Duplicates       []*File //id重复的文件(夹),只保留第一次出现的
+	Cycles           []*File //ParentId形成环的文件(夹),包括挂在环下面的
+	VolumeMismatches []*File //VolumeId和父目录不一致的文件(夹),仍然会挂到目录树上
+}
+
+//HasProblem 是否发现了问题
+func (r *BuildReport) HasProblem() bool {
+	return len(r.Orphans) > 0 || len(r.Duplicates) > 0 || len(r.Cycles) > 0 || len(r.VolumeMismatches) > 0
+}
+
+/*
+	NewDirFromFiles
+	用平铺的文件列表(如一次SQL查出的整个卷)按ParentId构建目录树,depth,count,size都会设置好。
+	files里面有id为rootId的文件夹时以它为根节点,否则rootId<=0时以虚拟目录为根节点,rootId>0时返回ErrRootNotFound。
+	孤儿,重复id,环以及VolumeId不一致的情况会记录在BuildReport里,不会返回错误。
+*/
+func NewDirFromFiles(ctx context.Context, rootId, volumeId int64, files []*File) (*Dir, *BuildReport, error) {
+	report := &BuildReport{}
+	byId := make(map[int64]*File, len(files))
+	var uniqFiles []*File
+	for _, file := range files {
+		if _, ok := byId[file.Id]; ok {
+			report.Duplicates = append(report.Duplicates, file)
+			continue
+		}
+		byId[file.Id] = file
+		uniqFiles = append(uniqFiles, file)
+	}
+
+	var root *Dir
+	if rootFile, ok := byId[rootId]; ok {
+		root = NewDir(rootFile, 0, unKnown, unKnown)
+		if root == nil {
+			return nil, nil, fmt.Errorf("root id=%d: %w", rootId, ErrNotFolderType)
+		}
+	} else if rootId <= 0 {
+		root = NewVirtualDir(rootId, volumeId, typeFolder)
+	} else {
+		return nil, nil, fmt.Errorf("root id=%d: %w", rootId, ErrRootNotFound)
+	}
+
+	children := make(map[int64][]*File) //parentId -> 子文件(夹)
+	orphans := make(map[int64]bool)
+	for _, file := range uniqFiles {
+		if file.Id == rootId {
+			continue
+		}
+		parent, ok := byId[file.ParentId]
+		if file.ParentId != rootId && (!ok || !parent.IsFolder()) {
+			orphans[file.Id] = true
+			continue
+		}
+		children[file.ParentId] = append(children[file.ParentId], file)
+	}
+
+	reached := map[int64]bool{rootId: true}
+	err := root.bfsLevels(func(dir *Dir, _ int) error {
+		if err := ctxErr(ctx); err != nil {
+			return err
+		}
+		var subFiles, subFolders []*File
+		for _, file := range children[dir.originInfo.Id] {
+			reached[file.Id] = true
+			if file.VolumeId != dir.originInfo.VolumeId {
+				report.VolumeMismatches = append(report.VolumeMismatches, file)
+			}
+			if file.IsFolder() {
+				subFolders = append(subFolders, file)
+			} else {
+				subFiles = append(subFiles, file)
+			}
+		}
+		return dir.fillNoRecurse(ctx, subFiles, subFolders)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	root.recomputeTotals()
+
+	//没有挂到目录树上的:沿着ParentId往上找,最终遇到孤儿的算孤儿,否则就是环
+	const (
+		statusOrphan = 1
+		statusCycle  = 2
+	)
+	status := make(map[int64]int)
+	for _, file := range uniqFiles {
+		if reached[file.Id] {
+			continue
+		}
+		var path []int64
+		onPath := make(map[int64]bool)
+		result := statusCycle
+		for id := file.Id; ; id = byId[id].ParentId {
+			if orphans[id] {
+				result = statusOrphan
+				break
+			}
+			if s, ok := status[id]; ok {
+				result = s
+				break
+			}
+			if onPath[id] {
+				break
+			}
+			onPath[id] = true
+			path = append(path, id)
+		}
+		for _, id := range path {
+			status[id] = result
+		}
+	}
+	for _, file := range uniqFiles {
+		if orphans[file.Id] || status[file.Id] == statusOrphan {
+			report.Orphans = append(report.Orphans, file)
+		} else if status[file.Id] == statusCycle {
+			report.Cycles = append(report.Cycles, file)
+		}
+	}
+	return root, report, nil
+}
@@ -0,0 +1,93 @@
+package dirtree
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// flatFilesForTest 测试树的所有文件(夹),DFS顺序
+func flatFilesForTest() []*File {
+	buildTreeForTest()
+	dir := newNewVirtualDirForTest()
+	if _, _, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil); err != nil {
+		panic(err)
+	}
+	return dir.GetAllFoldersAndFiles(nil)
+}
+
+func fileIds(files []*File) []int64 {
+	var ids []int64
+	for _, file := range files {
+		ids = append(ids, file.Id)
+	}
+	return ids
+}
+
+func TestNewDirFromFiles(t *testing.T) {
+	Convey("TestNewDirFromFiles", t, func() {
+		Convey("TestNewDirFromFiles success", func() {
+			files := flatFilesForTest()
+			dir, report, err := NewDirFromFiles(nil, 0, 1, files)
+			So(err, ShouldBeNil)
+			So(report.HasProblem(), ShouldBeFalse)
+			So(dir.IsVirtualDir(), ShouldBeTrue)
+			So(fileIds(dir.GetAllFoldersAndFiles(nil)), ShouldResemble, fileIds(files))
+			totalSize, totalCount, err := dir.GetTotalSizeAndCount(nil)
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 19)
+			So(totalSize, ShouldEqual, 10)
+			So(dir.GetTotalSize(), ShouldEqual, 10)
+			So(dir.GetTotalFileCount()+dir.GetTotalFolderCount(), ShouldEqual, 19)
+			dir33 := dir.GetSubDirs()[0].GetSubDirs()[0].GetSubDirs()[0]
+			So(dir33.GetId(), ShouldEqual, 33)
+			So(dir33.GetDepth(), ShouldEqual, 3)
+			So(dir33.GetCount(), ShouldEqual, 1)
+			So(dir33.GetSize(), ShouldEqual, 1)
+		})
+
+		Convey("TestNewDirFromFiles real root", func() {
+			full, _, _ := NewDirFromFiles(nil, 0, 1, flatFilesForTest())
+			files := full.GetSubDirs()[0].GetAllFoldersAndFiles(nil) //只包括12及其子树
+			dir, report, err := NewDirFromFiles(nil, 12, 1, files)
+			So(err, ShouldBeNil)
+			So(report.HasProblem(), ShouldBeFalse)
+			So(dir.GetId(), ShouldEqual, 12)
+			So(dir.GetDepth(), ShouldEqual, 0)
+			_, totalCount, _ := dir.GetTotalSizeAndCount(nil)
+			So(totalCount, ShouldEqual, 13)
+
+			_, _, err = NewDirFromFiles(nil, 999, 1, files)
+			So(errors.Is(err, ErrRootNotFound), ShouldBeTrue)
+			_, _, err = NewDirFromFiles(nil, 20, 1, files)
+			So(errors.Is(err, ErrNotFolderType), ShouldBeTrue)
+		})
+
+		Convey("TestNewDirFromFiles problems", func() {
+			files := flatFilesForTest()
+			files = append(files,
+				&File{Id: 10, ParentId: 12, VolumeId: 1, Type: typeFile},            //重复id
+				&File{Id: 400, ParentId: 999, VolumeId: 1, Type: typeFolder},        //孤儿
+				&File{Id: 401, ParentId: 400, VolumeId: 1, Type: typeFile},          //挂在孤儿下面
+				&File{Id: 402, ParentId: 10, VolumeId: 1, Type: typeFile},           //父节点是文件
+				&File{Id: 500, ParentId: 501, VolumeId: 1, Type: typeFolder},        //环
+				&File{Id: 501, ParentId: 500, VolumeId: 1, Type: typeFolder},        //环
+				&File{Id: 502, ParentId: 501, VolumeId: 1, Type: typeFile},          //挂在环下面
+				&File{Id: 503, ParentId: 503, VolumeId: 1, Type: typeFolder},        //自己是自己的父目录
+				&File{Id: 600, ParentId: 12, VolumeId: 2, Type: typeFolder},         //VolumeId不一致
+				&File{Id: 601, ParentId: 600, VolumeId: 2, Type: typeFile, Size: 5}, //跟父目录一致
+			)
+			dir, report, err := NewDirFromFiles(nil, 0, 1, files)
+			So(err, ShouldBeNil)
+			So(report.HasProblem(), ShouldBeTrue)
+			So(fileIds(report.Duplicates), ShouldResemble, []int64{10})
+			So(fileIds(report.Orphans), ShouldResemble, []int64{400, 401, 402})
+			So(fileIds(report.Cycles), ShouldResemble, []int64{500, 501, 502, 503})
+			So(fileIds(report.VolumeMismatches), ShouldResemble, []int64{600})
+			totalSize, totalCount, _ := dir.GetTotalSizeAndCount(nil)
+			So(totalCount, ShouldEqual, 21)
+			So(totalSize, ShouldEqual, 15)
+		})
+	})
+}
@@ -0,0 +1,266 @@
+package dirtree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sync"
+)
+
+//FSIdMode File.Id的生成方式
+type FSIdMode int
+
+const (
+	FSIdGenerated FSIdMode = iota //按发现顺序从1开始生成,同一路径的id不变
+	FSIdInode                     //使用inode号,取不到inode或者inode重复(硬链接)时退化为生成的id
+)
+
+//fsFallbackIdBase FSIdInode模式下生成的id从这里开始,实际的inode号不会到这个范围,所以不会冲突
+const fsFallbackIdBase int64 = 1 << 62
+
+//SymlinkPolicy 符号链接的处理方式
+type SymlinkPolicy int
+
+const (
+	SymlinkSkip   SymlinkPolicy = iota //跳过符号链接
+	SymlinkAsFile                      //把符号链接本身当作文件
+	SymlinkFollow                      //跟随符号链接,指向文件夹时当作文件夹,断开的链接会被跳过
+)
+
+//PermissionPolicy 没有权限读取的文件(夹)的处理方式
+type PermissionPolicy int
+
+const (
+	PermissionSkip  PermissionPolicy = iota //跳过,没有权限读取的文件夹当作空文件夹
+	PermissionError                         //返回错误
+)
+
+//FSOption FSSource的选项
+type FSOption func(s *FSSource)
+
+//WithFSIdMode 指定File.Id的生成方式,默认FSIdGenerated
+func WithFSIdMode(mode FSIdMode) FSOption {
+	return func(s *FSSource) {
+		s.idMode = mode
+	}
+}
+
+//WithSymlinkPolicy 指定符号链接的处理方式,默认SymlinkSkip
+func WithSymlinkPolicy(policy SymlinkPolicy) FSOption {
+	return func(s *FSSource) {
+		s.symlink = policy
+	}
+}
+
+//WithPermissionPolicy 指定没有权限时的处理方式,默认PermissionSkip
+func WithPermissionPolicy(policy PermissionPolicy) FSOption {
+	return func(s *FSSource) {
+		s.permission = policy
+	}
+}
+
+/*
+	FSSource
+	把本地目录或任意fs.FS适配成目录树的数据源,Retrieve可以直接作为RetrieveNextDepthFilesFunc使用。
+	Size,Mtime取自fs.FileInfo,本地目录在linux下还会填充inode,Ctime以及owner uid(Creator)。
+	跟随符号链接时,FSIdInode模式会跳过已经出现过的文件夹来避免环,FSIdGenerated模式只能靠maxDepth限制。
+	FSIdInode模式下同一个inode的文件出现在多个路径(硬链接,或者跟随符号链接指向同一个文件)时,
+	第一次发现的路径使用inode号,其它路径使用生成的id,保证树上的File.Id不重复。
+*/
+type FSSource struct {
+	fsys       fs.FS
+	volumeId   int64
+	idMode     FSIdMode
+	symlink    SymlinkPolicy
+	permission PermissionPolicy
+
+	mu     sync.Mutex
+	root   *File
+	paths  map[int64]string  //文件夹id -> 路径
+	ids    map[string]int64  //路径 -> 生成的id
+	inodes map[uint64]string //FSIdInode模式下inode -> 第一次发现的路径
+	nextId int64
+}
+
+//NewFSSource 以fsys的根目录(".")为树根
+func NewFSSource(fsys fs.FS, volumeId int64, opts ...FSOption) (*FSSource, error) {
+	s := &FSSource{
+		fsys:     fsys,
+		volumeId: volumeId,
+		paths:    make(map[int64]string),
+		ids:      make(map[string]int64),
+		inodes:   make(map[uint64]string),
+	}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	if s.idMode == FSIdInode {
+		s.nextId = fsFallbackIdBase
+	}
+	info, err := fs.Stat(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("fs root: %w", ErrNotFolderType)
+	}
+	s.root = s.newFile(".", unKnown, info, typeFolder)
+	s.root.Name = ""
+	s.paths[s.root.Id] = "."
+	return s, nil
+}
+
+//NewOSSource 以本地目录dir为树根
+func NewOSSource(dir string, volumeId int64, opts ...FSOption) (*FSSource, error) {
+	return NewFSSource(os.DirFS(dir), volumeId, opts...)
+}
+
+//RootDir 新建一个还没加载的根目录Dir
+func (s *FSSource) RootDir() *Dir {
+	return NewDir(s.root, 0, unKnown, unKnown)
+}
+
+//Path 文件夹id对应的路径(相对fs.FS的根目录),只有已经被Retrieve发现的文件夹才有
+func (s *FSSource) Path(folderId int64) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.paths[folderId]
+	return p, ok
+}
+
+//Load 从根目录开始DFSLoad,返回加载好的根目录
+func (s *FSSource) Load(ctx context.Context, maxDepth, numLimit, sizeLimit int64,
+	opts ...LoadOption) (root *Dir, totalSize, totalCount int64, err error) {
+	root = s.RootDir()
+	totalSize, totalCount, err = root.DFSLoad(ctx, maxDepth, numLimit, sizeLimit, s.Retrieve, nil, nil, opts...)
+	return root, totalSize, totalCount, err
+}
+
+//Retrieve 实现RetrieveNextDepthFilesFunc,可并发调用
+func (s *FSSource) Retrieve(ctx context.Context, volumeId, folderId int64) (files, folders []*File, err error) {
+	dirPath, ok := s.Path(folderId)
+	if !ok {
+		return nil, nil, fmt.Errorf("folderId=%d: %w", folderId, fs.ErrNotExist)
+	}
+	entries, err := fs.ReadDir(s.fsys, dirPath)
+	if err != nil {
+		if errors.Is(err, fs.ErrPermission) && s.permission == PermissionSkip {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	for _, entry := range entries {
+		if err = ctxErr(ctx); err != nil {
+			return nil, nil, err
+		}
+		file, err := s.entryFile(folderId, path.Join(dirPath, entry.Name()), entry)
+		if err != nil {
+			return nil, nil, err
+		}
+		if file == nil {
+			continue
+		}
+		if file.IsFolder() {
+			folders = append(folders, file)
+		} else {
+			files = append(files, file)
+		}
+	}
+	return files, folders, nil
+}
+
+//entryFile 把目录项转成File,返回nil表示跳过
+func (s *FSSource) entryFile(parentId int64, entryPath string, entry fs.DirEntry) (*File, error) {
+	info, err := entry.Info()
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) { //读目录之后被删除了
+			return nil, nil
+		}
+		if errors.Is(err, fs.ErrPermission) && s.permission == PermissionSkip {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if info.Mode()&fs.ModeSymlink != 0 {
+		switch s.symlink {
+		case SymlinkSkip:
+			return nil, nil
+		case SymlinkFollow:
+			target, err := fs.Stat(s.fsys, entryPath)
+			if err != nil {
+				return nil, nil
+			}
+			info = target
+		}
+	}
+	fileType := typeFile
+	if info.IsDir() {
+		fileType = typeFolder
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	file := s.newFile(entryPath, parentId, info, fileType)
+	if file.IsFolder() {
+		if oldPath, ok := s.paths[file.Id]; ok && oldPath != entryPath {
+			return nil, nil //同一个文件夹出现在两个路径(如跟随符号链接形成环),只保留第一次
+		}
+		s.paths[file.Id] = entryPath
+	}
+	return file, nil
+}
+
+//newFile 调用时需要持有s.mu(NewFSSource除外)
+func (s *FSSource) newFile(entryPath string, parentId int64, info fs.FileInfo, fileType int) *File {
+	file := &File{
+		Id:       s.idFor(entryPath, info, fileType),
+		ParentId: parentId,
+		VolumeId: s.volumeId,
+		Name:     info.Name(),
+		Type:     fileType,
+		Version:  info.ModTime().UnixNano(),
+		Ctime:    info.ModTime().Unix(),
+		Mtime:    info.ModTime().Unix(),
+	}
+	if fileType == typeFile {
+		file.Size = info.Size()
+	}
+	if sys, ok := statSys(info); ok {
+		file.Ctime = sys.ctime
+		file.Creator = sys.uid
+	}
+	return file
+}
+
+//idFor 调用时需要持有s.mu。文件夹的inode重复时仍然返回inode号,由entryFile当作环跳过
+func (s *FSSource) idFor(entryPath string, info fs.FileInfo, fileType int) int64 {
+	if s.idMode == FSIdInode {
+		if sys, ok := statSys(info); ok && sys.ino > 0 && sys.ino < uint64(fsFallbackIdBase) {
+			firstPath, seen := s.inodes[sys.ino]
+			if !seen {
+				s.inodes[sys.ino] = entryPath
+			}
+			if !seen || firstPath == entryPath || fileType == typeFolder {
+				return int64(sys.ino)
+			}
+		}
+	}
+	if id, ok := s.ids[entryPath]; ok {
+		return id
+	}
+	s.nextId++
+	s.ids[entryPath] = s.nextId
+	return s.nextId
+}
+
+//fileSys 从fs.FileInfo.Sys()取到的系统信息
+type fileSys struct {
+	ino   uint64
+	uid   int64
+	ctime int64
+}
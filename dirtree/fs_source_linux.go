@@ -0,0 +1,20 @@
+//go:build linux
+
+package dirtree
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+func statSys(info fs.FileInfo) (fileSys, bool) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileSys{}, false
+	}
+	return fileSys{
+		ino:   st.Ino,
+		uid:   int64(st.Uid),
+		ctime: int64(st.Ctim.Sec),
+	}, true
+}
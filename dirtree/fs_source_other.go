@@ -0,0 +1,12 @@
+//go:build !linux
+
+package dirtree
+
+import (
+	"io/fs"
+)
+
+//statSys 非linux平台不读取inode等信息
+func statSys(info fs.FileInfo) (fileSys, bool) {
+	return fileSys{}, false
+}
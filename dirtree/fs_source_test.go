@@ -0,0 +1,164 @@
+package dirtree
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+//deniedFS 模拟没有权限读取的路径
+type deniedFS struct {
+	fs.FS
+	denied map[string]bool
+}
+
+func (f deniedFS) Open(name string) (fs.File, error) {
+	if f.denied[name] {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return f.FS.Open(name)
+}
+
+func newMapFSForTest() fstest.MapFS {
+	mtime := time.Unix(1700000000, 0)
+	return fstest.MapFS{
+		"a.txt":           {Data: []byte("hello"), ModTime: mtime},
+		"docs/b.txt":      {Data: []byte("ab"), ModTime: mtime},
+		"docs/2024/c.txt": {Data: []byte("abc"), ModTime: mtime},
+		"empty":           {Mode: fs.ModeDir, ModTime: mtime},
+	}
+}
+
+func TestFSSource(t *testing.T) {
+	Convey("TestFSSource", t, func() {
+		Convey("TestFSSource load fs.FS", func() {
+			source, err := NewFSSource(newMapFSForTest(), 7)
+			So(err, ShouldBeNil)
+			root, totalSize, totalCount, err := source.Load(nil, -1, -1, -1)
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 7) //根目录,a.txt,docs,empty,b.txt,2024,c.txt
+			So(totalSize, ShouldEqual, 10)
+			So(root.GetId(), ShouldEqual, 1)
+
+			var names []string
+			for _, file := range root.GetAllFoldersAndFilesByBfs(nil) {
+				names = append(names, file.Name)
+				So(file.VolumeId, ShouldEqual, 7)
+			}
+			So(names, ShouldResemble, []string{"", "docs", "empty", "a.txt", "2024", "b.txt", "c.txt"})
+
+			docs := root.GetSubDirs()[0]
+			So(docs.GetDirOriginInfo().ParentId, ShouldEqual, root.GetId())
+			So(docs.GetSubFiles()[0].ParentId, ShouldEqual, docs.GetId())
+			So(docs.GetSubFiles()[0].Mtime, ShouldEqual, 1700000000)
+			docsPath, ok := source.Path(docs.GetSubDirs()[0].GetId())
+			So(ok, ShouldBeTrue)
+			So(docsPath, ShouldEqual, "docs/2024")
+		})
+
+		Convey("TestFSSource permission denied", func() {
+			fsys := deniedFS{FS: newMapFSForTest(), denied: map[string]bool{"docs": true}}
+			source, err := NewFSSource(fsys, 1)
+			So(err, ShouldBeNil)
+			root, _, totalCount, err := source.Load(nil, -1, -1, -1)
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 4)
+			So(root.GetSubDirs()[0].GetCount(), ShouldEqual, 0)
+
+			source, err = NewFSSource(fsys, 1, WithPermissionPolicy(PermissionError))
+			So(err, ShouldBeNil)
+			_, _, _, err = source.Load(nil, -1, -1, -1)
+			So(errors.Is(err, fs.ErrPermission), ShouldBeTrue)
+		})
+
+		Convey("TestFSSource local dir and symlinks", func() {
+			dir := t.TempDir()
+			So(os.MkdirAll(filepath.Join(dir, "sub"), 0o755), ShouldBeNil)
+			So(os.WriteFile(filepath.Join(dir, "sub", "f.txt"), []byte("12345"), 0o644), ShouldBeNil)
+			So(os.Symlink("f.txt", filepath.Join(dir, "sub", "link.txt")), ShouldBeNil)
+			So(os.Symlink("..", filepath.Join(dir, "sub", "loop")), ShouldBeNil)
+
+			loadNames := func(opts ...FSOption) []string {
+				source, err := NewOSSource(dir, 1, opts...)
+				So(err, ShouldBeNil)
+				root, _, _, err := source.Load(context.Background(), 10, -1, -1)
+				So(err, ShouldBeNil)
+				var names []string
+				for _, file := range root.GetAllFoldersAndFiles(nil) {
+					names = append(names, file.TypeString()+":"+file.Name)
+				}
+				return names
+			}
+			So(loadNames(), ShouldResemble, []string{"folder:", "folder:sub", "file:f.txt"})
+			So(loadNames(WithSymlinkPolicy(SymlinkAsFile)), ShouldResemble,
+				[]string{"folder:", "folder:sub", "file:f.txt", "file:link.txt", "file:loop"})
+
+			info, err := os.Stat(dir)
+			So(err, ShouldBeNil)
+			sys, ok := statSys(info)
+			if !ok {
+				return //非linux平台没有inode
+			}
+			//inode模式下loop指向根目录,会被跳过
+			So(loadNames(WithFSIdMode(FSIdInode), WithSymlinkPolicy(SymlinkFollow)), ShouldResemble,
+				[]string{"folder:", "folder:sub", "file:f.txt", "file:link.txt"})
+			source, err := NewOSSource(dir, 1, WithFSIdMode(FSIdInode))
+			So(err, ShouldBeNil)
+			root, _, _, err := source.Load(nil, -1, -1, -1)
+			So(err, ShouldBeNil)
+			So(root.GetId(), ShouldEqual, int64(sys.ino))
+			file := root.GetSubDirs()[0].GetSubFiles()[0]
+			So(file.Size, ShouldEqual, 5)
+			So(file.Creator, ShouldEqual, os.Getuid())
+			So(file.Ctime, ShouldBeGreaterThan, 0)
+		})
+
+		Convey("TestFSSource inode ids never repeat", func() {
+			//取不到inode时使用生成的id,和inode号不在同一个范围
+			source, err := NewFSSource(newMapFSForTest(), 1, WithFSIdMode(FSIdInode))
+			So(err, ShouldBeNil)
+			root, _, _, err := source.Load(nil, -1, -1, -1)
+			So(err, ShouldBeNil)
+			So(root.GetId(), ShouldBeGreaterThan, fsFallbackIdBase)
+			for _, file := range root.GetAllFoldersAndFiles(nil) {
+				So(file.Id, ShouldBeGreaterThan, fsFallbackIdBase)
+			}
+
+			dir := t.TempDir()
+			So(os.WriteFile(filepath.Join(dir, "f.txt"), []byte("12345"), 0o644), ShouldBeNil)
+			So(os.Link(filepath.Join(dir, "f.txt"), filepath.Join(dir, "hard.txt")), ShouldBeNil)
+			So(os.Symlink("f.txt", filepath.Join(dir, "link.txt")), ShouldBeNil)
+			info, err := os.Stat(filepath.Join(dir, "f.txt"))
+			So(err, ShouldBeNil)
+			sys, ok := statSys(info)
+			if !ok {
+				return //非linux平台没有inode
+			}
+			source, err = NewOSSource(dir, 1, WithFSIdMode(FSIdInode), WithSymlinkPolicy(SymlinkFollow))
+			So(err, ShouldBeNil)
+			root, _, _, err = source.Load(nil, -1, -1, -1)
+			So(err, ShouldBeNil)
+			files := root.GetSubFiles()
+			So(len(files), ShouldEqual, 3)
+			So(files[0].Name, ShouldEqual, "f.txt")
+			So(files[0].Id, ShouldEqual, int64(sys.ino))
+			So(files[1].Id, ShouldBeGreaterThan, fsFallbackIdBase)
+			So(files[2].Id, ShouldBeGreaterThan, fsFallbackIdBase)
+			So(files[1].Id, ShouldNotEqual, files[2].Id)
+
+			//重新拉取时同一个路径的id不变
+			files2, _, err := source.Retrieve(nil, 1, root.GetId())
+			So(err, ShouldBeNil)
+			So(fileIds(files2), ShouldResemble, fileIds(files))
+			index := root.EnableIndex()
+			So(index.Len(), ShouldEqual, 4) //根目录和3个文件
+		})
+	})
+}
@@ -0,0 +1,127 @@
+package dirtree
+
+import (
+	"sync"
+)
+
+/*
+	Index
+	目录树的id索引,通过Dir.EnableIndex开启。
+	开启之后FillDirNoRecurse以及各种加载方法新增的节点都会自动加入索引,可以并发读写。
+*/
+type Index struct {
+	mu    sync.RWMutex
+	nodes map[int64]*indexNode
+}
+
+type indexNode struct {
+	file   *File
+	dir    *Dir //文件夹对应的Dir,文件为nil
+	parent *Dir //根节点为nil
+}
+
+//EnableIndex 为当前dir所在的树开启id索引(当前dir作为根),已经加载的节点会立即加入索引,重复调用返回同一个Index
+func (d *Dir) EnableIndex() *Index {
+	if d.index != nil {
+		return d.index
+	}
+	index := &Index{nodes: make(map[int64]*indexNode)}
+	index.nodes[d.originInfo.Id] = &indexNode{file: d.originInfo, dir: d}
+	d.attachIndex(index)
+	return index
+}
+
+//attachIndex 把index挂到已经加载的子树上
+func (d *Dir) attachIndex(index *Index) {
+	_ = d.dfsStack(func(dir *Dir) error {
+		dir.index = index
+		if !dir.loaded {
+			return errSkipSubtree
+		}
+		index.addChildren(dir)
+		return nil
+	}, nil)
+}
+
+//GetIndex 获取id索引,没有开启时返回nil
+func (d *Dir) GetIndex() *Index {
+	return d.index
+}
+
+//addChildren 把dir的直接子节点加入索引
+func (idx *Index) addChildren(dir *Dir) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for _, subDir := range dir.subDirs {
+		idx.nodes[subDir.originInfo.Id] = &indexNode{file: subDir.originInfo, dir: subDir, parent: dir}
+	}
+	for _, file := range dir.subFiles {
+		idx.nodes[file.Id] = &indexNode{file: file, parent: dir}
+	}
+}
+
+//set 加入或者更新一个节点
+func (idx *Index) set(file *File, dir *Dir, parent *Dir) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.nodes[file.Id] = &indexNode{file: file, dir: dir, parent: parent}
+}
+
+//remove 删除节点
+func (idx *Index) remove(ids []int64) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for _, id := range ids {
+		delete(idx.nodes, id)
+	}
+}
+
+//Len 索引中的节点数量,包括根节点
+func (idx *Index) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.nodes)
+}
+
+//Get 按id获取节点,文件夹同时返回对应的Dir
+func (idx *Index) Get(id int64) (file *File, dir *Dir, ok bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	node, ok := idx.nodes[id]
+	if !ok {
+		return nil, nil, false
+	}
+	return node.file, node.dir, true
+}
+
+//GetDir 按id获取文件夹对应的Dir
+func (idx *Index) GetDir(id int64) (*Dir, bool) {
+	_, dir, ok := idx.Get(id)
+	return dir, ok && dir != nil
+}
+
+//Parent 节点所在的Dir,根节点或者找不到时返回false
+func (idx *Index) Parent(id int64) (*Dir, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	node, ok := idx.nodes[id]
+	if !ok || node.parent == nil {
+		return nil, false
+	}
+	return node.parent, true
+}
+
+//Ancestors 节点的所有祖先,从父目录一直到根节点
+func (idx *Index) Ancestors(id int64) []*Dir {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	var ancestors []*Dir
+	for {
+		node, ok := idx.nodes[id]
+		if !ok || node.parent == nil {
+			return ancestors
+		}
+		ancestors = append(ancestors, node.parent)
+		id = node.parent.originInfo.Id
+	}
+}
@@ -0,0 +1,83 @@
+package dirtree
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func dirIds(dirs []*Dir) []int64 {
+	var ids []int64
+	for _, dir := range dirs {
+		ids = append(ids, dir.GetId())
+	}
+	return ids
+}
+
+func TestIndex(t *testing.T) {
+	Convey("TestIndex", t, func() {
+		Convey("TestIndex enable before load", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			index := dir.EnableIndex()
+			So(dir.EnableIndex(), ShouldEqual, index)
+			So(index.Len(), ShouldEqual, 1)
+			_, _, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil)
+			So(err, ShouldBeNil)
+			So(index.Len(), ShouldEqual, 20)
+
+			for _, file := range dir.GetAllFoldersAndFiles(nil) {
+				found, subDir, ok := index.Get(file.Id)
+				So(ok, ShouldBeTrue)
+				So(found, ShouldEqual, file)
+				So(subDir != nil, ShouldEqual, file.IsFolder())
+				parent, ok := index.Parent(file.Id)
+				So(ok, ShouldBeTrue)
+				So(parent.GetId(), ShouldEqual, file.ParentId)
+			}
+
+			subDir, ok := index.GetDir(33)
+			So(ok, ShouldBeTrue)
+			So(subDir.GetSubFiles()[0].Id, ShouldEqual, 41)
+			_, ok = index.GetDir(41)
+			So(ok, ShouldBeFalse)
+			_, ok = index.Parent(0)
+			So(ok, ShouldBeFalse)
+			_, _, ok = index.Get(99)
+			So(ok, ShouldBeFalse)
+
+			So(dirIds(index.Ancestors(41)), ShouldResemble, []int64{33, 22, 12, 0})
+			So(dirIds(index.Ancestors(12)), ShouldResemble, []int64{0})
+			So(index.Ancestors(0), ShouldBeEmpty)
+		})
+
+		Convey("TestIndex enable after partial load", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			_, _, err := dir.BFSLoad(nil, 2, -1, -1, getSubFilesMock, nil, nil, WithPartialResult(nil))
+			So(err, ShouldBeNil)
+			index := dir.EnableIndex()
+			So(index.Len(), ShouldEqual, 10)
+			_, _, ok := index.Get(41)
+			So(ok, ShouldBeFalse)
+
+			subDir, _ := index.GetDir(22)
+			files, folders, _ := getSubFilesMock(nil, 1, 22)
+			So(subDir.FillDirNoRecurse(nil, files, folders), ShouldBeNil)
+			So(index.Len(), ShouldEqual, 14)
+			parent, ok := index.Parent(33)
+			So(ok, ShouldBeTrue)
+			So(parent, ShouldEqual, subDir)
+		})
+
+		Convey("TestIndex ConcurrentLoad", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			index := dir.EnableIndex()
+			_, _, err := dir.ConcurrentLoad(nil, 4, -1, -1, -1, getSubFilesMock, nil, nil)
+			So(err, ShouldBeNil)
+			So(index.Len(), ShouldEqual, 20)
+			So(dirIds(index.Ancestors(42)), ShouldResemble, []int64{37, 24, 13, 0})
+		})
+	})
+}
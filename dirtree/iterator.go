@@ -0,0 +1,208 @@
+package dirtree
+
+import (
+	"context"
+)
+
+/*
+	Iterator
+	拉取式遍历器,每次Next只前进一个文件(夹),不会一次性生成整个切片,不再需要时直接丢弃即可。
+	用法:
+		it := dir.IterDFS(ctx)
+		for it.Next() {
+			file := it.File()
+			...
+		}
+		if err := it.Err(); err != nil {
+			...
+		}
+	注意:遍历过程中不能修改目录树。
+*/
+type Iterator struct {
+	ctx    context.Context
+	bfs    bool   //true按BFS顺序,false按DFS顺序
+	start  *Dir   //遍历的起点
+	root   *Dir   //不为nil表示下一次Next先输出起点本身
+	queue  []*Dir //待展开的dir,DFS从尾部取,BFS从头部取
+	curr   *Dir   //正在输出子条目的dir
+	pos    int    //curr下一个要输出的子条目
+	file   *File
+	dir    *Dir
+	depth  int64
+	parent *Dir
+	err    error
+}
+
+//IterDFS DFS顺序的遍历器,顺序和GetAllFoldersAndFiles一致(不包括虚节点),遇到没有加载的dir时停止并通过Err返回,
+//部分加载时被截断的dir以及加载时被SkipDir跳过的dir当作叶子节点
+func (d *Dir) IterDFS(ctx context.Context) *Iterator {
+	return d.newIterator(ctx, false)
+}
+
+//IterBFS BFS顺序的遍历器,顺序和GetAllFoldersAndFilesByBfs一致(不包括虚节点),没有加载的子目录当作空目录
+func (d *Dir) IterBFS(ctx context.Context) *Iterator {
+	return d.newIterator(ctx, true)
+}
+
+func (d *Dir) newIterator(ctx context.Context, bfs bool) *Iterator {
+	it := &Iterator{ctx: ctx, bfs: bfs, start: d, queue: []*Dir{d}}
+	if !d.IsVirtualDir() { //非虚拟的才包括根文件夹
+		it.root = d
+	}
+	return it
+}
+
+//Next 前进到下一个文件(夹),没有更多文件(夹)或者出错时返回false
+func (it *Iterator) Next() bool {
+	it.file, it.dir, it.parent = nil, nil, nil
+	if it.err != nil {
+		return false
+	}
+	if it.root != nil {
+		it.file, it.dir, it.depth, it.parent = it.root.originInfo, it.root, it.root.depth, it.root.parent
+		it.root = nil
+		return true
+	}
+	for it.curr == nil || it.pos >= len(it.curr.subDirs)+len(it.curr.subFiles) {
+		if len(it.queue) == 0 {
+			it.curr = nil
+			return false
+		}
+		if it.err = it.expand(it.pop()); it.err != nil {
+			it.curr = nil
+			return false
+		}
+	}
+
+	it.depth, it.parent = it.curr.depth+1, it.curr
+	if it.pos < len(it.curr.subDirs) { //先子文件夹后子文件,和GetSubFoldersAndFiles一致
+		it.dir = it.curr.subDirs[it.pos]
+		it.file = it.dir.originInfo
+	} else {
+		it.file = it.curr.subFiles[it.pos-len(it.curr.subDirs)]
+	}
+	it.pos++
+	return true
+}
+
+//pop 取出下一个要展开的dir
+func (it *Iterator) pop() *Dir {
+	var dir *Dir
+	if it.bfs {
+		dir = it.queue[0]
+		it.queue[0] = nil
+		it.queue = it.queue[1:]
+	} else {
+		dir = it.queue[len(it.queue)-1]
+		it.queue = it.queue[:len(it.queue)-1]
+	}
+	return dir
+}
+
+//expand 开始输出dir的子条目,并把子目录放入待展开队列
+func (it *Iterator) expand(dir *Dir) error {
+	if err := ctxErr(it.ctx); err != nil {
+		return err
+	}
+	if !dir.traversable() && (!it.bfs || dir == it.start) { //和DfsWithFunc,BfsWithFunc的检查一致
+		return newDirError("traverse", dir, ErrDirNotLoad)
+	}
+	it.curr, it.pos = dir, 0
+	if it.bfs {
+		it.queue = append(it.queue, dir.subDirs...)
+		return nil
+	}
+	for i := len(dir.subDirs) - 1; i >= 0; i-- { //倒序入栈,保证按顺序出栈
+		it.queue = append(it.queue, dir.subDirs[i])
+	}
+	return nil
+}
+
+//File 当前的文件(夹)
+func (it *Iterator) File() *File {
+	return it.file
+}
+
+//Dir 当前是文件夹时返回对应的Dir,纯文件返回nil
+func (it *Iterator) Dir() *Dir {
+	return it.dir
+}
+
+//Depth 当前文件(夹)在目录树中的层级
+func (it *Iterator) Depth() int64 {
+	return it.depth
+}
+
+//Parent 当前文件(夹)所在的dir,起点本身返回它的父目录
+func (it *Iterator) Parent() *Dir {
+	return it.parent
+}
+
+//Err 遍历出错的原因(ctx取消或者dir没有加载),正常结束返回nil
+func (it *Iterator) Err() error {
+	return it.err
+}
+
+//LevelIterator 按层的遍历器,每次Next前进一层,顺序和GetAllFoldersAndFilesOnLevel一致
+type LevelIterator struct {
+	ctx   context.Context
+	start *Dir
+	root  bool   //下一次Next先输出起点本身所在的一层
+	dirs  []*Dir //下一层文件(夹)所属的dir
+	files []*File
+	depth int64
+	err   error
+}
+
+//IterLevels 按层的遍历器,不包括虚节点,没有加载的子目录当作空目录
+func (d *Dir) IterLevels(ctx context.Context) *LevelIterator {
+	return &LevelIterator{ctx: ctx, start: d, root: !d.IsVirtualDir(), dirs: []*Dir{d}, depth: d.depth}
+}
+
+//Next 前进到下一层,没有更多层或者出错时返回false
+func (it *LevelIterator) Next() bool {
+	it.files = nil
+	if it.err != nil {
+		return false
+	}
+	if it.root {
+		it.root = false
+		it.files = []*File{it.start.originInfo}
+		return true
+	}
+	if len(it.dirs) == 0 {
+		return false
+	}
+	if !it.start.traversable() {
+		it.err = newDirError("traverse", it.start, ErrDirNotLoad)
+		it.dirs = nil
+		return false
+	}
+	var nextDirs []*Dir
+	for _, dir := range it.dirs {
+		if it.err = ctxErr(it.ctx); it.err != nil {
+			it.dirs = nil
+			return false
+		}
+		it.files = append(it.files, dir.GetSubFoldersAndFiles()...)
+		nextDirs = append(nextDirs, dir.subDirs...)
+	}
+	it.dirs = nextDirs
+	it.depth++
+	return true
+}
+
+//Files 当前层的文件(夹)
+func (it *LevelIterator) Files() []*File {
+	return it.files
+}
+
+//Depth 当前层的层级
+func (it *LevelIterator) Depth() int64 {
+	return it.depth
+}
+
+//Err 遍历出错的原因,正常结束返回nil
+func (it *LevelIterator) Err() error {
+	return it.err
+}
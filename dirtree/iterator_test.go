@@ -0,0 +1,117 @@
+package dirtree
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestIterator(t *testing.T) {
+	Convey("TestIterator", t, func() {
+		buildTreeForTest()
+		dir := newNewVirtualDirForTest()
+		_, _, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil)
+		So(err, ShouldBeNil)
+
+		Convey("TestIterator IterDFS", func() {
+			var ids []int64
+			it := dir.IterDFS(nil)
+			for it.Next() {
+				So(it.Depth(), ShouldEqual, it.Parent().GetDepth()+1)
+				So(it.File().ParentId, ShouldEqual, it.Parent().GetId())
+				So(it.Dir() != nil, ShouldEqual, it.File().IsFolder())
+				ids = append(ids, it.File().Id)
+			}
+			So(it.Err(), ShouldBeNil)
+			So(ids, ShouldResemble, []int64{12, 13, 10, 11, 22, 23, 20, 21, 33, 30, 31, 32, 41, 35, 36, 34, 24, 37, 42})
+			So(it.Next(), ShouldBeFalse)
+			So(it.File(), ShouldBeNil)
+
+			dir12 := dir.GetSubDirs()[0]
+			it = dir12.IterDFS(nil)
+			So(it.Next(), ShouldBeTrue)
+			So(it.File().Id, ShouldEqual, 12)
+			So(it.Depth(), ShouldEqual, 1)
+			So(it.Parent(), ShouldEqual, dir)
+		})
+
+		Convey("TestIterator IterBFS", func() {
+			var ids []int64
+			it := dir.IterBFS(nil)
+			for it.Next() {
+				ids = append(ids, it.File().Id)
+			}
+			So(it.Err(), ShouldBeNil)
+			So(ids, ShouldResemble, []int64{12, 13, 10, 11, 22, 23, 20, 21, 24, 33, 30, 31, 32, 35, 36, 34, 37, 41, 42})
+		})
+
+		Convey("TestIterator early termination", func() {
+			it := dir.IterDFS(nil)
+			for it.Next() {
+				if it.File().Id == 22 {
+					break
+				}
+			}
+			So(it.File().Id, ShouldEqual, 22)
+			So(it.Next(), ShouldBeTrue)
+			So(it.File().Id, ShouldEqual, 23)
+		})
+
+		Convey("TestIterator IterLevels", func() {
+			var depths []int64
+			var levels [][]int64
+			it := dir.IterLevels(nil)
+			for it.Next() {
+				depths = append(depths, it.Depth())
+				levels = append(levels, fileIds(it.Files()))
+			}
+			So(it.Err(), ShouldBeNil)
+			So(depths, ShouldResemble, []int64{1, 2, 3, 4})
+			So(levels, ShouldResemble, [][]int64{{12, 13, 10, 11}, {22, 23, 20, 21, 24}, {33, 30, 31, 32, 35, 36, 34, 37}, {41, 42}})
+
+			depths = nil
+			for it := dir.GetSubDirs()[1].IterLevels(nil); it.Next(); {
+				depths = append(depths, it.Depth())
+			}
+			So(depths, ShouldResemble, []int64{1, 2, 3, 4})
+		})
+
+		Convey("TestIterator unloaded dir", func() {
+			dir := unloadedDirForTest()
+
+			var ids []int64
+			it := dir.IterDFS(nil)
+			for it.Next() {
+				ids = append(ids, it.File().Id)
+			}
+			So(errors.Is(it.Err(), ErrDirNotLoad), ShouldBeTrue)
+			So(ids, ShouldResemble, []int64{12, 13, 10, 11, 22, 23, 20, 21})
+
+			ids = nil
+			bfsIt := dir.IterBFS(nil)
+			for bfsIt.Next() {
+				ids = append(ids, bfsIt.File().Id)
+			}
+			So(bfsIt.Err(), ShouldBeNil)
+			So(ids, ShouldResemble, []int64{12, 13, 10, 11, 22, 23, 20, 21, 24, 35, 36, 34, 37, 42})
+
+			unloaded := newNewVirtualDirForTest()
+			So(unloaded.IterDFS(nil).Next(), ShouldBeFalse)
+			levelIt := unloaded.IterLevels(nil)
+			So(levelIt.Next(), ShouldBeFalse)
+			So(errors.Is(levelIt.Err(), ErrDirNotLoad), ShouldBeTrue)
+		})
+
+		Convey("TestIterator context cancel", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			it := dir.IterDFS(ctx)
+			So(it.Next(), ShouldBeTrue)
+			cancel()
+			for it.Next() {
+			}
+			So(it.Err(), ShouldEqual, context.Canceled)
+		})
+	})
+}
@@ -0,0 +1,47 @@
+package dirtree
+
+import (
+	"context"
+	"time"
+)
+
+//LoadOption 加载选项,DFSLoad,BFSLoad和ConcurrentLoad通用
+type LoadOption func(opts *loadOptions)
+
+type loadOptions struct {
+	partial  bool          //部分加载模式,触发限制时不返回错误
+	report   *LoadReport   //部分加载模式下的报告,可以为nil
+	logger   Logger        //为nil时使用包级别的Logger
+	timeout  time.Duration //整个加载过程的时间预算,0表示不限制
+	validate bool          //加载成功结束后检查目录树的不变式
+
+	retrievePage RetrieveFilesPageFunc //不为nil时分页拉取,代替RetrieveNextDepthFilesFunc
+}
+
+func newLoadOptions(opts []LoadOption) *loadOptions {
+	options := &loadOptions{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(options)
+		}
+	}
+	return options
+}
+
+//WithTimeout 整个加载过程的时间预算,超时后停止加载并返回context.DeadlineExceeded
+func WithTimeout(timeout time.Duration) LoadOption {
+	return func(opts *loadOptions) {
+		opts.timeout = timeout
+	}
+}
+
+//context 加载使用的ctx,nil时使用context.Background,并且加上时间预算
+func (opts *loadOptions) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if opts.timeout > 0 {
+		return context.WithTimeout(ctx, opts.timeout)
+	}
+	return context.WithCancel(ctx)
+}
@@ -0,0 +1,84 @@
+package dirtree
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+//Logger 日志接口,和log/slog兼容,可以直接传入*slog.Logger。
+//args是key-value形式的结构化字段,如"folderId",1,"depth",2
+type Logger interface {
+	InfoContext(ctx context.Context, msg string, args ...any)
+	WarnContext(ctx context.Context, msg string, args ...any)
+	ErrorContext(ctx context.Context, msg string, args ...any)
+}
+
+//nopLogger 默认的Logger,不输出任何日志
+type nopLogger struct{}
+
+func (nopLogger) InfoContext(ctx context.Context, msg string, args ...any)  {}
+func (nopLogger) WarnContext(ctx context.Context, msg string, args ...any)  {}
+func (nopLogger) ErrorContext(ctx context.Context, msg string, args ...any) {}
+
+//loggerHolder atomic.Value要求每次存储的类型一致
+type loggerHolder struct {
+	logger Logger
+}
+
+var pkgLogger atomic.Value
+
+func init() {
+	pkgLogger.Store(loggerHolder{logger: nopLogger{}})
+}
+
+//SetLogger 设置包级别的Logger,没有通过WithLogger指定时使用,传nil表示不输出日志
+func SetLogger(logger Logger) {
+	if logger == nil {
+		logger = nopLogger{}
+	}
+	pkgLogger.Store(loggerHolder{logger: logger})
+}
+
+func getLogger() Logger {
+	return pkgLogger.Load().(loggerHolder).logger
+}
+
+//WithLogger 指定本次加载使用的Logger,优先于SetLogger设置的包级别Logger
+func WithLogger(logger Logger) LoadOption {
+	return func(opts *loadOptions) {
+		opts.logger = logger
+	}
+}
+
+//start 记录加载开始
+func (info *dsfLoadInfo) start(ctx context.Context, root *Dir, loader string) {
+	info.logger.InfoContext(ctx, "dirtree load start",
+		"loader", loader,
+		"volumeId", root.originInfo.VolumeId,
+		"folderId", root.originInfo.Id,
+		"depth", root.depth,
+		"maxDepth", info.maxDepth,
+		"numLimit", info.numLimit,
+		"sizeLimit", info.sizeLimit)
+}
+
+//logLimitHit 记录触发限制
+func (info *dsfLoadInfo) logLimitHit(ctx context.Context, limitErr *LimitError) {
+	info.logger.WarnContext(ctx, "dirtree limit hit",
+		"limit", limitErr.Limit.Error(),
+		"max", limitErr.Max,
+		"volumeId", limitErr.VolumeId,
+		"folderId", limitErr.FolderId,
+		"depth", limitErr.Depth,
+		"totalCount", limitErr.TotalCount,
+		"totalSize", limitErr.TotalSize)
+}
+
+//logRetrieveFailed 记录RetrieveNextDepthFilesFunc出错
+func (info *dsfLoadInfo) logRetrieveFailed(ctx context.Context, dir *Dir, err error) {
+	info.logger.ErrorContext(ctx, "dirtree retrieve failed",
+		"volumeId", dir.originInfo.VolumeId,
+		"folderId", dir.originInfo.Id,
+		"depth", dir.depth,
+		"err", err)
+}
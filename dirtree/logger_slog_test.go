@@ -0,0 +1,115 @@
+//go:build go1.21
+
+package dirtree
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+//slogRecords 解析slog.JSONHandler输出的每一行
+func slogRecords(buf *bytes.Buffer) []map[string]any {
+	var records []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		record := make(map[string]any)
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			panic(err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func findSlogRecords(records []map[string]any, msg string) []map[string]any {
+	var found []map[string]any
+	for _, record := range records {
+		if record["msg"] == msg {
+			found = append(found, record)
+		}
+	}
+	return found
+}
+
+func TestSlogLogger(t *testing.T) {
+	Convey("TestSlogLogger", t, func() {
+		buf := &bytes.Buffer{}
+		logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+		Convey("TestSlogLogger start and finish", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			_, _, err := dir.DFSLoad(nil, 10, -1, -1, getSubFilesMock, nil, nil, WithLogger(logger))
+			So(err, ShouldBeNil)
+			records := slogRecords(buf)
+			So(len(records), ShouldEqual, 2)
+
+			start := records[0]
+			So(start["msg"], ShouldEqual, "dirtree load start")
+			So(start["level"], ShouldEqual, "INFO")
+			So(start["loader"], ShouldEqual, "dfs")
+			So(start["folderId"], ShouldEqual, 0) //json数字解析为float64
+			So(start["volumeId"], ShouldEqual, 1)
+			So(start["depth"], ShouldEqual, 0)
+			So(start["maxDepth"], ShouldEqual, 10)
+
+			finish := records[1]
+			So(finish["msg"], ShouldEqual, "dirtree load finish")
+			So(finish["level"], ShouldEqual, "INFO")
+			So(finish["totalCount"], ShouldEqual, 19)
+			So(finish["totalSize"], ShouldEqual, 10)
+			So(finish["truncated"], ShouldEqual, false)
+		})
+
+		Convey("TestSlogLogger limit hit", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			_, _, err := dir.BFSLoad(nil, -1, 12, -1, getSubFilesMock, nil, nil, WithLogger(logger))
+			So(errors.Is(err, ErrFileNumLimit), ShouldBeTrue)
+			records := slogRecords(buf)
+			hits := findSlogRecords(records, "dirtree limit hit")
+			So(len(hits), ShouldEqual, 1)
+			So(hits[0]["level"], ShouldEqual, "WARN")
+			So(hits[0]["limit"], ShouldEqual, ErrFileNumLimit.Error())
+			So(hits[0]["max"], ShouldEqual, 12)
+			So(hits[0]["folderId"], ShouldEqual, 22)
+			So(hits[0]["depth"], ShouldEqual, 2)
+			So(hits[0]["totalCount"], ShouldEqual, 13)
+			finish := findSlogRecords(records, "dirtree load finish")
+			So(len(finish), ShouldEqual, 1)
+			So(finish[0]["level"], ShouldEqual, "ERROR")
+			So(finish[0]["err"], ShouldContainSubstring, ErrFileNumLimit.Error())
+		})
+
+		Convey("TestSlogLogger retrieve failed with package logger", func() {
+			SetLogger(logger)
+			defer SetLogger(nil)
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			errRetrieve := errors.New("backend down")
+			failRetrieve := func(ctx context.Context, volumeId, folderId int64) (files, folders []*File, err error) {
+				if folderId == 13 {
+					return nil, nil, errRetrieve
+				}
+				return getSubFilesMock(ctx, volumeId, folderId)
+			}
+			_, _, err := dir.DFSLoad(nil, -1, -1, -1, failRetrieve, nil, nil)
+			So(errors.Is(err, errRetrieve), ShouldBeTrue)
+			failed := findSlogRecords(slogRecords(buf), "dirtree retrieve failed")
+			So(len(failed), ShouldEqual, 1)
+			So(failed[0]["level"], ShouldEqual, "ERROR")
+			So(failed[0]["folderId"], ShouldEqual, 13)
+			So(failed[0]["depth"], ShouldEqual, 1)
+			So(failed[0]["err"], ShouldEqual, "backend down")
+		})
+	})
+}
@@ -0,0 +1,118 @@
+package dirtree
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type logEvent struct {
+	level string
+	msg   string
+	attrs map[string]any
+}
+
+//recordLogger 记录日志事件,用于测试
+type recordLogger struct {
+	mu     sync.Mutex
+	events []*logEvent
+}
+
+func (l *recordLogger) record(level, msg string, args []any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	event := &logEvent{level: level, msg: msg, attrs: make(map[string]any)}
+	for i := 0; i+1 < len(args); i += 2 {
+		event.attrs[args[i].(string)] = args[i+1]
+	}
+	l.events = append(l.events, event)
+}
+
+func (l *recordLogger) InfoContext(ctx context.Context, msg string, args ...any) {
+	l.record("info", msg, args)
+}
+
+func (l *recordLogger) WarnContext(ctx context.Context, msg string, args ...any) {
+	l.record("warn", msg, args)
+}
+
+func (l *recordLogger) ErrorContext(ctx context.Context, msg string, args ...any) {
+	l.record("error", msg, args)
+}
+
+func (l *recordLogger) find(msg string) []*logEvent {
+	var events []*logEvent
+	for _, event := range l.events {
+		if event.msg == msg {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func TestLogger(t *testing.T) {
+	Convey("TestLogger", t, func() {
+		Convey("TestLogger start and finish", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			logger := &recordLogger{}
+			_, _, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil, WithLogger(logger))
+			So(err, ShouldBeNil)
+			So(len(logger.events), ShouldEqual, 2)
+			So(logger.events[0].msg, ShouldEqual, "dirtree load start")
+			So(logger.events[0].attrs["loader"], ShouldEqual, "dfs")
+			So(logger.events[1].msg, ShouldEqual, "dirtree load finish")
+			So(logger.events[1].attrs["totalCount"], ShouldEqual, 19)
+			So(logger.events[1].attrs["totalSize"], ShouldEqual, 10)
+		})
+
+		Convey("TestLogger limit hit", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			logger := &recordLogger{}
+			_, _, err := dir.BFSLoad(nil, -1, 12, -1, getSubFilesMock, nil, nil, WithLogger(logger))
+			So(err, ShouldNotBeNil)
+			hits := logger.find("dirtree limit hit")
+			So(len(hits), ShouldEqual, 1)
+			So(hits[0].level, ShouldEqual, "warn")
+			So(hits[0].attrs["folderId"], ShouldEqual, 22)
+			So(hits[0].attrs["depth"], ShouldEqual, 2)
+			So(hits[0].attrs["totalCount"], ShouldEqual, 13)
+			finish := logger.find("dirtree load finish")
+			So(len(finish), ShouldEqual, 1)
+			So(finish[0].level, ShouldEqual, "error")
+		})
+
+		Convey("TestLogger retrieve failed", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			logger := &recordLogger{}
+			failRetrieve := func(ctx context.Context, volumeId, folderId int64) (files, folders []*File, err error) {
+				if folderId == 13 {
+					return nil, nil, context.Canceled
+				}
+				return getSubFilesMock(ctx, volumeId, folderId)
+			}
+			_, _, err := dir.DFSLoad(nil, -1, -1, -1, failRetrieve, nil, nil, WithLogger(logger))
+			So(err, ShouldNotBeNil)
+			failed := logger.find("dirtree retrieve failed")
+			So(len(failed), ShouldEqual, 1)
+			So(failed[0].attrs["folderId"], ShouldEqual, 13)
+			So(failed[0].attrs["depth"], ShouldEqual, 1)
+		})
+
+		Convey("TestLogger package logger", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			logger := &recordLogger{}
+			SetLogger(logger)
+			defer SetLogger(nil)
+			_, _, err := dir.ConcurrentLoad(nil, 4, -1, -1, -1, getSubFilesMock, nil, nil)
+			So(err, ShouldBeNil)
+			So(len(logger.find("dirtree load start")), ShouldEqual, 1)
+			So(len(logger.find("dirtree load finish")), ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,303 @@
+package dirtree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+/*
+	修改已加载的目录树
+	AddNode,RemoveNode,MoveNode,RenameNode都以当前dir的子树为范围按id查找节点,
+	开启了id索引时是O(1)查找,否则需要遍历子树;AddNode还要检查id在整棵树里是否重复,没有索引时是O(n)的遍历,
+	大树上频繁新增节点时应该先EnableIndex。
+	修改时会同步维护File.ParentId,depth,父目录的count和size,所有受影响祖先的累计统计,父目录指针以及id索引。
+	注意:这些方法不是并发安全的,需要并发访问时使用SafeTree或者自己加锁。
+*/
+
+//AddNode 在parentId对应的文件夹下新增一个文件或文件夹,parentId所在的dir需要已经加载。
+//新增的文件夹是一个已加载的空目录,可以继续在它下面新增节点。file.ParentId会被设置为parentId
+func (d *Dir) AddNode(ctx context.Context, parentId int64, file *File) error {
+	return d.addNode(ctx, parentId, file, true)
+}
+
+//addNode 新增节点,loaded为false时新增的文件夹是未加载状态(内容未知)
+func (d *Dir) addNode(ctx context.Context, parentId int64, file *File, loaded bool) error {
+	_, parent, _, err := d.findNode(ctx, "add", parentId)
+	if err != nil {
+		return err
+	}
+	if parent == nil {
+		return fmt.Errorf("dirtree add id=%d: %w", parentId, ErrNotFolderType)
+	}
+	if err = parent.canAdd(file); err != nil {
+		return err
+	}
+	//没有开启索引时需要从根节点遍历整棵树检查id是否重复
+	if _, _, _, err = d.Root().findNode(ctx, "add", file.Id); err == nil {
+		return fmt.Errorf("dirtree add id=%d: %w", file.Id, ErrDuplicateId)
+	} else if !errors.Is(err, ErrNodeNotFound) {
+		return err
+	}
+	parent.addChild(file, loaded)
+	return nil
+}
+
+//canAdd 检查能否在d下面新增file,不检查id是否重复
+func (d *Dir) canAdd(file *File) error {
+	if !d.loaded {
+		return newDirError("add", d, ErrDirNotLoad)
+	}
+	if file.VolumeId != d.originInfo.VolumeId {
+		return newDirError("add", d, ErrVolumeMismatch)
+	}
+	return nil
+}
+
+//addChild 把file加到d的直接子节点中,调用方需要先检查canAdd和id是否重复
+func (d *Dir) addChild(file *File, loaded bool) {
+	var dir *Dir
+	if file.IsFolder() {
+		if loaded {
+			dir = NewDir(file, d.depth+1, 0, 0)
+			dir.totals.store(dirTotals{})
+		} else {
+			dir = NewDir(file, d.depth+1, unKnown, unKnown)
+		}
+		dir.loaded = loaded
+		dir.index = d.index
+	}
+	d.attach(file, dir)
+	if d.index != nil {
+		d.index.set(file, dir, d)
+	}
+}
+
+//RemoveNode 删除一个文件或者整个文件夹子树,返回被删除的节点。被删除的文件夹成为一棵独立的树,不再属于原来的索引
+func (d *Dir) RemoveNode(ctx context.Context, id int64) (*File, error) {
+	file, dir, parent, err := d.findNode(ctx, "remove", id)
+	if err != nil {
+		return nil, err
+	}
+	if parent == nil || dir == d {
+		return nil, fmt.Errorf("dirtree remove id=%d: %w", id, ErrModifyRoot)
+	}
+	parent.detach(file, dir)
+
+	if parent.index != nil {
+		ids := []int64{id}
+		if dir != nil {
+			_ = dir.dfsStack(func(subDir *Dir) error {
+				subDir.index = nil
+				for _, file := range subDir.GetSubFoldersAndFiles() {
+					ids = append(ids, file.Id)
+				}
+				return nil
+			}, nil)
+		}
+		parent.index.remove(ids)
+	}
+	return file, nil
+}
+
+//MoveNode 把一个文件或文件夹移动到newParentId对应的文件夹下,不能把文件夹移动到它自己或者它的子孙下面
+func (d *Dir) MoveNode(ctx context.Context, id, newParentId int64) error {
+	file, dir, oldParent, err := d.findNode(ctx, "move", id)
+	if err != nil {
+		return err
+	}
+	if oldParent == nil || dir == d {
+		return fmt.Errorf("dirtree move id=%d: %w", id, ErrModifyRoot)
+	}
+	_, newParent, _, err := d.findNode(ctx, "move", newParentId)
+	if err != nil {
+		return err
+	}
+	if newParent == nil {
+		return fmt.Errorf("dirtree move id=%d: %w", newParentId, ErrNotFolderType)
+	}
+	if err = newParent.canMoveIn(file, dir); err != nil {
+		return err
+	}
+	oldParent.moveChild(file, dir, newParent)
+	return nil
+}
+
+//canMoveIn 检查能否把节点移动到d下面
+func (d *Dir) canMoveIn(file *File, dir *Dir) error {
+	if dir != nil && d.isWithin(dir) {
+		return newDirError("move", dir, ErrMoveIntoSelf)
+	}
+	if !d.loaded {
+		return newDirError("move", d, ErrDirNotLoad)
+	}
+	if file.VolumeId != d.originInfo.VolumeId {
+		return newDirError("move", d, ErrVolumeMismatch)
+	}
+	return nil
+}
+
+//moveChild 把d的直接子节点移动到newParent下面,调用方需要先检查canMoveIn
+func (d *Dir) moveChild(file *File, dir, newParent *Dir) {
+	if newParent == d {
+		return
+	}
+	d.detach(file, dir)
+	if dir != nil && newParent.depth+1 != dir.depth { //层级不变时不修改子树,SafeTree里面的子树可能和快照共享
+		delta := newParent.depth + 1 - dir.depth
+		_ = dir.dfsStack(func(subDir *Dir) error {
+			subDir.depth += delta
+			return nil
+		}, nil)
+	}
+	newParent.attach(file, dir)
+	if newParent.index != nil {
+		newParent.index.set(file, dir, newParent)
+	}
+}
+
+//UpdateNode 用info更新节点的Name,Version,Size,Ctime,Creator,Mtime和Modifier,
+//不会修改Id,ParentId,VolumeId和Type,移动节点使用MoveNode
+func (d *Dir) UpdateNode(ctx context.Context, id int64, info *File) error {
+	file, dir, parent, err := d.findNode(ctx, "update", id)
+	if err != nil {
+		return err
+	}
+	updateFile(file, dir, parent, info)
+	return nil
+}
+
+//updateFile 用info更新file的属性,并更新parent以及祖先的统计
+func updateFile(file *File, dir, parent *Dir, info *File) {
+	sizeDelta, oldMtime := info.Size-file.Size, file.Mtime
+	file.Name = info.Name
+	file.Version = info.Version
+	file.Size = info.Size
+	file.Ctime = info.Ctime
+	file.Creator = info.Creator
+	file.Mtime = info.Mtime
+	file.Modifier = info.Modifier
+	if parent == nil {
+		return
+	}
+	if dir == nil && sizeDelta != 0 { //文件夹自身的Size不计入统计
+		parent.size += sizeDelta
+		parent.addTotals(dirTotals{size: sizeDelta})
+	}
+	if file.Mtime > oldMtime {
+		parent.addTotals(dirTotals{latestMtime: file.Mtime})
+	} else if file.Mtime < oldMtime {
+		parent.refreshLatestMtime()
+	}
+}
+
+//RenameNode 修改文件或文件夹的名字
+func (d *Dir) RenameNode(ctx context.Context, id int64, name string) error {
+	file, _, _, err := d.findNode(ctx, "rename", id)
+	if err != nil {
+		return err
+	}
+	file.Name = name
+	return nil
+}
+
+//findNode 在d的子树中按id查找节点,文件夹同时返回对应的Dir,parent是节点所在的Dir
+func (d *Dir) findNode(ctx context.Context, op string, id int64) (file *File, dir *Dir, parent *Dir, err error) {
+	if d.originInfo.Id == id {
+		return d.originInfo, d, d.parent, nil
+	}
+	if d.index != nil {
+		if file, dir, ok := d.index.Get(id); ok {
+			if parent, ok := d.index.Parent(id); ok && parent.isWithin(d) {
+				return file, dir, parent, nil
+			}
+		}
+	} else {
+		it := d.IterBFS(ctx)
+		for it.Next() {
+			if it.File().Id == id {
+				return it.File(), it.Dir(), it.Parent(), nil
+			}
+		}
+		if err = it.Err(); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return nil, nil, nil, fmt.Errorf("dirtree %s id=%d: %w", op, id, ErrNodeNotFound)
+}
+
+//isWithin d是否是ancestor本身或者它的子孙
+func (d *Dir) isWithin(ancestor *Dir) bool {
+	for dir := d; dir != nil; dir = dir.parent {
+		if dir == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+//nodeTotals 节点本身加上它的子树对祖先累计统计的贡献
+func nodeTotals(file *File, dir *Dir) dirTotals {
+	if dir == nil {
+		return dirTotals{size: file.Size, files: 1, latestMtime: file.Mtime}
+	}
+	t := dir.totals.load()
+	t.folders++
+	if file.Mtime > t.latestMtime {
+		t.latestMtime = file.Mtime
+	}
+	return t
+}
+
+//attach 把节点加到d的直接子节点中,更新ParentId,count,size和累计统计
+func (d *Dir) attach(file *File, dir *Dir) {
+	file.ParentId = d.originInfo.Id
+	if dir != nil {
+		dir.parent = d
+		d.subDirs = append(d.subDirs, dir)
+	} else {
+		d.subFiles = append(d.subFiles, file)
+		d.size += file.Size
+	}
+	d.count++
+	d.addTotals(nodeTotals(file, dir))
+}
+
+//detach 把节点从d的直接子节点中移除,更新count,size和累计统计
+func (d *Dir) detach(file *File, dir *Dir) {
+	if dir != nil {
+		for i, subDir := range d.subDirs {
+			if subDir == dir {
+				d.subDirs = append(d.subDirs[:i:i], d.subDirs[i+1:]...)
+				break
+			}
+		}
+		dir.parent = nil
+	} else {
+		for i, subFile := range d.subFiles {
+			if subFile == file {
+				d.subFiles = append(d.subFiles[:i:i], d.subFiles[i+1:]...)
+				break
+			}
+		}
+		d.size -= file.Size
+	}
+	d.count--
+	t := nodeTotals(file, dir)
+	d.addTotals(dirTotals{size: -t.size, files: -t.files, folders: -t.folders, unloaded: -t.unloaded})
+	d.refreshLatestMtime()
+}
+
+//refreshLatestMtime 删除节点之后latestMtime可能变小,从d开始向上重新计算
+func (d *Dir) refreshLatestMtime() {
+	for dir := d; dir != nil; dir = dir.parent {
+		latestMtime := dir.ownTotals().latestMtime
+		for _, subDir := range dir.subDirs {
+			if mtime := subDir.GetLatestMtime(); mtime > latestMtime {
+				latestMtime = mtime
+			}
+		}
+		atomic.StoreInt64(&dir.totals.latestMtime, latestMtime)
+	}
+}
@@ -0,0 +1,140 @@
+package dirtree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMutation(t *testing.T) {
+	Convey("TestMutation", t, func() {
+		for _, indexed := range []bool{false, true} {
+			indexed := indexed
+			Convey(fmt.Sprintf("TestMutation indexed=%v", indexed), func() {
+				buildTreeForTest()
+				setFakeMtimeForTest(map[int64]int64{41: 100, 42: 50})
+				dir := newNewVirtualDirForTest()
+				if indexed {
+					dir.EnableIndex()
+				}
+				_, _, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil)
+				So(err, ShouldBeNil)
+				dir12 := dir.GetSubDirs()[0]
+				dir13 := dir.GetSubDirs()[1]
+				dir22 := dir12.GetSubDirs()[0]
+				dir24 := dir13.GetSubDirs()[0]
+
+				Convey("TestMutation AddNode", func() {
+					So(dir.AddNode(nil, 22, &File{Id: 50, VolumeId: 1, Type: typeFile, Size: 5, Mtime: 200}), ShouldBeNil)
+					So(dir22.GetCount(), ShouldEqual, 5)
+					So(dir22.GetSize(), ShouldEqual, 8)
+					So(dir22.GetTotalSize(), ShouldEqual, 9)
+					So(dir12.GetTotalSize(), ShouldEqual, 12)
+					So(dir.GetTotalSize(), ShouldEqual, 15)
+					So(dir.GetTotalFileCount(), ShouldEqual, 11)
+					So(dir.GetLatestMtime(), ShouldEqual, 200)
+
+					folder := &File{Id: 51, VolumeId: 1, Type: typeFolder, Name: "new"}
+					So(dir.AddNode(nil, 24, folder), ShouldBeNil)
+					So(folder.ParentId, ShouldEqual, 24)
+					So(dir.AddNode(nil, 51, &File{Id: 52, VolumeId: 1, Type: typeFile, Size: 1}), ShouldBeNil)
+					dir51 := dir24.GetSubDirs()[1]
+					So(dir51.GetId(), ShouldEqual, 51)
+					So(dir51.GetDepth(), ShouldEqual, 3)
+					So(dir51.Parent(), ShouldEqual, dir24)
+					So(dir51.GetCount(), ShouldEqual, 1)
+					So(dir.GetTotalFolderCount(), ShouldEqual, 10)
+					So(dir.GetTotalFileCount(), ShouldEqual, 12)
+					if indexed {
+						parent, ok := dir.GetIndex().Parent(52)
+						So(ok, ShouldBeTrue)
+						So(parent, ShouldEqual, dir51)
+					}
+
+					So(errors.Is(dir.AddNode(nil, 22, &File{Id: 41, VolumeId: 1, Type: typeFile}), ErrDuplicateId), ShouldBeTrue)
+					So(errors.Is(dir.AddNode(nil, 99, &File{Id: 60, VolumeId: 1, Type: typeFile}), ErrNodeNotFound), ShouldBeTrue)
+					So(errors.Is(dir.AddNode(nil, 41, &File{Id: 60, VolumeId: 1, Type: typeFile}), ErrNotFolderType), ShouldBeTrue)
+					So(errors.Is(dir.AddNode(nil, 22, &File{Id: 60, VolumeId: 2, Type: typeFile}), ErrVolumeMismatch), ShouldBeTrue)
+
+					ctx, cancel := context.WithCancel(context.Background())
+					cancel()
+					err = dir.AddNode(ctx, 0, &File{Id: 41, VolumeId: 1, Type: typeFile})
+					if indexed {
+						So(errors.Is(err, ErrDuplicateId), ShouldBeTrue)
+					} else {
+						So(err, ShouldEqual, context.Canceled) //取消之后不能当作没有重复
+					}
+					So(dir.GetCount(), ShouldEqual, 4)
+				})
+
+				Convey("TestMutation RemoveNode", func() {
+					file, err := dir.RemoveNode(nil, 41)
+					So(err, ShouldBeNil)
+					So(file.Id, ShouldEqual, 41)
+					So(dir22.GetSubDirs()[0].GetCount(), ShouldEqual, 0)
+					So(dir.GetTotalSize(), ShouldEqual, 9)
+					So(dir.GetLatestMtime(), ShouldEqual, 50)
+					So(dir22.GetLatestMtime(), ShouldEqual, 0)
+
+					_, err = dir.RemoveNode(nil, 22)
+					So(err, ShouldBeNil)
+					So(dir22.Parent(), ShouldBeNil)
+					So(dir12.GetCount(), ShouldEqual, 3)
+					So(dir12.GetTotalSize(), ShouldEqual, 3)
+					So(dir.GetTotalFileCount(), ShouldEqual, 6)
+					So(dir.GetTotalFolderCount(), ShouldEqual, 7)
+					So(fileIds(dir12.GetSubFoldersAndFiles()), ShouldResemble, []int64{23, 20, 21})
+					if indexed {
+						_, _, ok := dir.GetIndex().Get(33)
+						So(ok, ShouldBeFalse)
+						So(dir.GetIndex().Len(), ShouldEqual, 14)
+					}
+
+					_, err = dir.RemoveNode(nil, 22)
+					So(errors.Is(err, ErrNodeNotFound), ShouldBeTrue)
+					_, err = dir12.RemoveNode(nil, 12)
+					So(errors.Is(err, ErrModifyRoot), ShouldBeTrue)
+				})
+
+				Convey("TestMutation MoveNode", func() {
+					So(dir.MoveNode(nil, 22, 24), ShouldBeNil)
+					So(dir22.Parent(), ShouldEqual, dir24)
+					So(dir22.GetDirOriginInfo().ParentId, ShouldEqual, 24)
+					So(dir22.GetDepth(), ShouldEqual, 3)
+					So(dir22.GetSubDirs()[0].GetDepth(), ShouldEqual, 4)
+					So(dir12.GetCount(), ShouldEqual, 3)
+					So(dir24.GetCount(), ShouldEqual, 2)
+					So(dir12.GetTotalSize(), ShouldEqual, 3)
+					So(dir13.GetTotalSize(), ShouldEqual, 5)
+					So(dir13.GetLatestMtime(), ShouldEqual, 100)
+					So(dir12.GetLatestMtime(), ShouldEqual, 0)
+					So(dir.GetTotalSize(), ShouldEqual, 10)
+					So(dir.GetTotalFolderCount(), ShouldEqual, 9)
+					if indexed {
+						parent, _ := dir.GetIndex().Parent(22)
+						So(parent, ShouldEqual, dir24)
+					}
+
+					So(dir.MoveNode(nil, 10, 22), ShouldBeNil)
+					So(dir.GetSize(), ShouldEqual, 1)
+					So(dir22.GetSize(), ShouldEqual, 4)
+					So(dir24.GetTotalSize(), ShouldEqual, 6)
+
+					So(errors.Is(dir.MoveNode(nil, 13, 33), ErrMoveIntoSelf), ShouldBeTrue)
+					So(errors.Is(dir.MoveNode(nil, 13, 13), ErrMoveIntoSelf), ShouldBeTrue)
+					So(errors.Is(dir.MoveNode(nil, 0, 13), ErrModifyRoot), ShouldBeTrue)
+					So(errors.Is(dir.MoveNode(nil, 11, 30), ErrNotFolderType), ShouldBeTrue)
+				})
+
+				Convey("TestMutation RenameNode", func() {
+					So(dir.RenameNode(nil, 33, "renamed"), ShouldBeNil)
+					So(dir22.GetSubDirs()[0].GetDirOriginInfo().Name, ShouldEqual, "renamed")
+					So(errors.Is(dir.RenameNode(nil, 99, "x"), ErrNodeNotFound), ShouldBeTrue)
+				})
+			})
+		}
+	})
+}
@@ -0,0 +1,189 @@
+package dirtree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+//Parent 父目录,根节点返回nil。父目录指针由FillDirNoRecurse和各种加载方法自动设置
+func (d *Dir) Parent() *Dir {
+	return d.parent
+}
+
+//Ancestors 所有祖先,从父目录一直到根节点
+func (d *Dir) Ancestors() []*Dir {
+	var ancestors []*Dir
+	for parent := d.parent; parent != nil; parent = parent.parent {
+		ancestors = append(ancestors, parent)
+	}
+	return ancestors
+}
+
+//Root 所在树的根节点
+func (d *Dir) Root() *Dir {
+	root := d
+	for root.parent != nil {
+		root = root.parent
+	}
+	return root
+}
+
+//Siblings 兄弟目录(不包括自己),根节点没有兄弟目录。兄弟文件可以通过Parent().GetSubFiles()获取
+func (d *Dir) Siblings() []*Dir {
+	if d.parent == nil {
+		return nil
+	}
+	var siblings []*Dir
+	for _, subDir := range d.parent.subDirs {
+		if subDir != d {
+			siblings = append(siblings, subDir)
+		}
+	}
+	return siblings
+}
+
+//LowestCommonAncestor 和other的最近公共祖先(可能是自己或other),不在同一棵树或者other为nil时返回nil
+func (d *Dir) LowestCommonAncestor(other *Dir) *Dir {
+	if other == nil {
+		return nil
+	}
+	a, b := d, other
+	depthA, depthB := a.levelFromRoot(), b.levelFromRoot()
+	for ; depthA > depthB; depthA-- {
+		a = a.parent
+	}
+	for ; depthB > depthA; depthB-- {
+		b = b.parent
+	}
+	for a != b {
+		if a.parent == nil || b.parent == nil {
+			return nil
+		}
+		a, b = a.parent, b.parent
+	}
+	return a
+}
+
+//levelFromRoot 到根节点的距离,不依赖depth字段
+func (d *Dir) levelFromRoot() int {
+	level := 0
+	for parent := d.parent; parent != nil; parent = parent.parent {
+		level++
+	}
+	return level
+}
+
+//RelativePath 从当前dir到to的相对路径,如"../../docs/2024",相同时返回".",不在同一棵树时返回ErrNotSameTree
+func (d *Dir) RelativePath(to *Dir) (string, error) {
+	lca := d.LowestCommonAncestor(to)
+	if lca == nil {
+		return "", ErrNotSameTree
+	}
+	var segments []string
+	for dir := d; dir != lca; dir = dir.parent {
+		segments = append(segments, "..")
+	}
+	var down []string
+	for dir := to; dir != lca; dir = dir.parent {
+		down = append(down, dir.originInfo.Name)
+	}
+	for i := len(down) - 1; i >= 0; i-- {
+		segments = append(segments, down[i])
+	}
+	if len(segments) == 0 {
+		return ".", nil
+	}
+	return strings.Join(segments, "/"), nil
+}
+
+//Path 从根节点到当前dir的完整名字路径,以"/"开头,根节点是"/"
+func (d *Dir) Path() string {
+	rel, _ := d.Root().RelativePath(d)
+	if rel == "." {
+		return "/"
+	}
+	return "/" + rel
+}
+
+/*
+	从当前dir向下查找的导航方法
+	ParentOf,AncestorsOf,SiblingsOf从当前dir向下查找id对应的节点,只使用subDirs,不使用父目录指针,
+	所以也适用于SafeTree的快照(快照里面共享的子树的父目录指针可能指向旧版本的节点)。没有索引,需要遍历子树。
+*/
+
+//errNodeFound downPath找到节点时用来结束遍历
+var errNodeFound = errors.New("node found")
+
+//ParentOf id对应节点所在的dir,id是当前dir自己时返回nil
+func (d *Dir) ParentOf(ctx context.Context, id int64) (*Dir, error) {
+	path, _, err := d.downPath(ctx, id)
+	if err != nil || len(path) == 0 {
+		return nil, err
+	}
+	return path[len(path)-1], nil
+}
+
+//AncestorsOf id对应节点的所有祖先,从所在的dir一直到当前dir,id是当前dir自己时返回nil
+func (d *Dir) AncestorsOf(ctx context.Context, id int64) ([]*Dir, error) {
+	path, _, err := d.downPath(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+		path[i], path[j] = path[j], path[i]
+	}
+	return path, nil
+}
+
+//SiblingsOf id对应节点所在dir的其他子目录(不包括节点自己),id是当前dir自己时返回nil
+func (d *Dir) SiblingsOf(ctx context.Context, id int64) ([]*Dir, error) {
+	parent, err := d.ParentOf(ctx, id)
+	if err != nil || parent == nil {
+		return nil, err
+	}
+	var siblings []*Dir
+	for _, subDir := range parent.subDirs {
+		if subDir.originInfo.Id != id {
+			siblings = append(siblings, subDir)
+		}
+	}
+	return siblings, nil
+}
+
+//downPath 从当前dir到id对应节点所在dir的路径,file是节点本身,id是当前dir自己时path为空
+func (d *Dir) downPath(ctx context.Context, id int64) (path []*Dir, file *File, err error) {
+	if d.originInfo.Id == id {
+		return nil, d.originInfo, nil
+	}
+	err = d.dfsStack(func(dir *Dir) error {
+		if err := ctxErr(ctx); err != nil {
+			return err
+		}
+		path = append(path, dir)
+		for _, subDir := range dir.subDirs {
+			if subDir.originInfo.Id == id {
+				file = subDir.originInfo
+				return errNodeFound
+			}
+		}
+		for _, subFile := range dir.subFiles {
+			if subFile.Id == id {
+				file = subFile
+				return errNodeFound
+			}
+		}
+		return nil
+	}, func(dir *Dir) error {
+		path = path[:len(path)-1]
+		return nil
+	})
+	if err == errNodeFound {
+		return path, file, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, nil, fmt.Errorf("dirtree find id=%d: %w", id, ErrNodeNotFound)
+}
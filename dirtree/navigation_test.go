@@ -0,0 +1,97 @@
+package dirtree
+
+import (
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+//loadIndexedDirForTest 加载测试树并开启索引
+func loadIndexedDirForTest() (*Dir, *Index) {
+	buildTreeForTest()
+	dir := newNewVirtualDirForTest()
+	index := dir.EnableIndex()
+	if _, _, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil); err != nil {
+		panic(err)
+	}
+	return dir, index
+}
+
+func TestNavigation(t *testing.T) {
+	Convey("TestNavigation", t, func() {
+		root, index := loadIndexedDirForTest()
+		getDir := func(id int64) *Dir {
+			dir, ok := index.GetDir(id)
+			So(ok, ShouldBeTrue)
+			return dir
+		}
+		dir33, dir35, dir37 := getDir(33), getDir(35), getDir(37)
+
+		Convey("TestNavigation parent and ancestors", func() {
+			So(root.Parent(), ShouldBeNil)
+			So(dir33.Parent().GetId(), ShouldEqual, 22)
+			So(dirIds(dir33.Ancestors()), ShouldResemble, []int64{22, 12, 0})
+			So(root.Ancestors(), ShouldBeEmpty)
+			So(dir37.Root(), ShouldEqual, root)
+			So(root.Root(), ShouldEqual, root)
+			for _, file := range root.GetAllFolders(nil) {
+				dir := getDir(file.Id)
+				So(dir.Parent().GetId(), ShouldEqual, file.ParentId)
+				So(dir.GetDepth(), ShouldEqual, dir.Parent().GetDepth()+1)
+			}
+		})
+
+		Convey("TestNavigation siblings", func() {
+			So(dirIds(dir35.Siblings()), ShouldResemble, []int64{36})
+			So(dirIds(getDir(12).Siblings()), ShouldResemble, []int64{13})
+			So(dir33.Siblings(), ShouldBeEmpty)
+			So(root.Siblings(), ShouldBeEmpty)
+		})
+
+		Convey("TestNavigation lowest common ancestor", func() {
+			So(dir33.LowestCommonAncestor(dir35).GetId(), ShouldEqual, 12)
+			So(dir33.LowestCommonAncestor(dir37), ShouldEqual, root)
+			So(getDir(22).LowestCommonAncestor(dir33).GetId(), ShouldEqual, 22)
+			So(dir33.LowestCommonAncestor(dir33), ShouldEqual, dir33)
+			So(dir33.LowestCommonAncestor(newNewVirtualDirForTest()), ShouldBeNil)
+			So(dir33.LowestCommonAncestor(nil), ShouldBeNil)
+		})
+
+		Convey("TestNavigation relative path", func() {
+			rel, err := dir33.RelativePath(dir35)
+			So(err, ShouldBeNil)
+			So(rel, ShouldEqual, "../../12-23/23-35")
+			rel, err = root.RelativePath(dir33)
+			So(err, ShouldBeNil)
+			So(rel, ShouldEqual, "0-12/12-22/22-33")
+			rel, err = dir33.RelativePath(root)
+			So(err, ShouldBeNil)
+			So(rel, ShouldEqual, "../../..")
+			rel, err = dir33.RelativePath(dir33)
+			So(err, ShouldBeNil)
+			So(rel, ShouldEqual, ".")
+			_, err = dir33.RelativePath(newNewVirtualDirForTest())
+			So(errors.Is(err, ErrNotSameTree), ShouldBeTrue)
+			_, err = dir33.RelativePath(nil)
+			So(errors.Is(err, ErrNotSameTree), ShouldBeTrue)
+
+			So(dir33.Path(), ShouldEqual, "/0-12/12-22/22-33")
+			So(root.Path(), ShouldEqual, "/")
+		})
+
+		Convey("TestNavigation PathOf with index", func() {
+			namePath, err := root.PathOf(nil, 41)
+			So(err, ShouldBeNil)
+			So(namePath, ShouldEqual, "/0-12/12-22/22-33/33-41")
+			namePath, err = getDir(12).PathOf(nil, 41)
+			So(err, ShouldBeNil)
+			So(namePath, ShouldEqual, "/12-22/22-33/33-41")
+			namePath, err = getDir(12).PathOf(nil, 22)
+			So(err, ShouldBeNil)
+			So(namePath, ShouldEqual, "/12-22")
+			_, err = getDir(13).PathOf(nil, 41)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
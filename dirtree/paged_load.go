@@ -0,0 +1,67 @@
+package dirtree
+
+import (
+	"context"
+)
+
+/*
+	RetrieveFilesPageFunc
+	分页查找folder下一层子文件(夹),cursor为空表示第一页,
+	返回的nextCursor为空表示已经是最后一页,nextCursor是这个folder已经用过的cursor(比如A->B->A循环)时返回ErrRepeatedCursor,避免死循环
+*/
+type RetrieveFilesPageFunc func(ctx context.Context, volumeId, folderId int64, cursor string) (files, folders []*File, nextCursor string, err error)
+
+/*
+	WithPageRetrieve
+	使用分页接口拉取每个folder的下一层数据,代替RetrieveNextDepthFilesFunc(此时可以传nil)。
+	每拉取一页都会检查numLimit和sizeLimit,超大的folder在拉取完所有页之前就会停止;
+	部分加载模式下会保留已经拉取的页,并把该dir标记为truncated。
+*/
+func WithPageRetrieve(retrievePage RetrieveFilesPageFunc) LoadOption {
+	return func(opts *loadOptions) {
+		opts.retrievePage = retrievePage
+	}
+}
+
+//retrievePages 逐页拉取dir的下一层数据,每页之后检查限制
+func (info *dsfLoadInfo) retrievePages(ctx context.Context, dir *Dir) (files, folders []*File, err error) {
+	var cursor string
+	var count, size int64             //已拉取的数量和大小
+	seen := map[string]bool{"": true} //已经用过的cursor
+	for {
+		if err = ctxErr(ctx); err != nil {
+			return nil, nil, err
+		}
+		pageFiles, pageFolders, nextCursor, err := info.opts.retrievePage(ctx, dir.originInfo.VolumeId, dir.originInfo.Id, cursor)
+		if err != nil {
+			info.logRetrieveFailed(ctx, dir, err)
+			return nil, nil, newDirError("retrieve", dir, err)
+		}
+		if nextCursor != "" && seen[nextCursor] {
+			err = newDirError("retrieve", dir, ErrRepeatedCursor)
+			info.logRetrieveFailed(ctx, dir, err)
+			return nil, nil, err
+		}
+		files = append(files, pageFiles...)
+		folders = append(folders, pageFolders...)
+		count += int64(len(pageFiles) + len(pageFolders))
+		for _, file := range pageFiles {
+			size += file.Size
+		}
+		if nextCursor == "" {
+			return files, folders, nil
+		}
+		if err = info.checkPending(ctx, dir, count, size); err != nil {
+			return files, folders, err
+		}
+		cursor = nextCursor
+		seen[cursor] = true
+	}
+}
+
+//checkPending 把还没累加的count和size算上检查限制,可并发调用
+func (info *dsfLoadInfo) checkPending(ctx context.Context, dir *Dir, count, size int64) error {
+	info.mu.Lock()
+	defer info.mu.Unlock()
+	return info.checkLimit(ctx, dir, info.totalCount+count, info.totalSize+size)
+}
@@ -0,0 +1,139 @@
+package dirtree
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+//newPagedMock 把getSubFilesMock的结果按pageSize分页,pages记录每个folder拉取的页数
+func newPagedMock(pageSize int, pages map[int64]int) RetrieveFilesPageFunc {
+	return func(ctx context.Context, volumeId, folderId int64, cursor string) (files, folders []*File, nextCursor string, err error) {
+		allFiles, allFolders, _ := getSubFilesMock(ctx, volumeId, folderId)
+		all := append(append([]*File{}, allFolders...), allFiles...)
+		offset := 0
+		if cursor != "" {
+			offset, _ = strconv.Atoi(cursor)
+		}
+		end := offset + pageSize
+		if end < len(all) {
+			nextCursor = strconv.Itoa(end)
+		} else {
+			end = len(all)
+		}
+		pages[folderId]++
+		files, folders = filterFilesAndFolders(all[offset:end])
+		return files, folders, nextCursor, nil
+	}
+}
+
+//buildBigFolderForTest 根目录下100个文件
+func buildBigFolderForTest() {
+	fakeParentAndSons = make(map[*File][]*File)
+	initRootFakeParent(1, 0)
+	for i := int64(1); i <= 100; i++ {
+		insertFakeFile(1, 0, 1000+i, 1, typeFile)
+	}
+}
+
+func TestPagedLoad(t *testing.T) {
+	Convey("TestPagedLoad", t, func() {
+		Convey("TestPagedLoad DFSLoad success", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			pages := make(map[int64]int)
+			totalSize, totalCount, err := dir.DFSLoad(nil, -1, -1, -1, nil, nil, nil, WithPageRetrieve(newPagedMock(2, pages)))
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 19)
+			So(totalSize, ShouldEqual, 10)
+			So(pages[0], ShouldEqual, 2)
+			So(pages[22], ShouldEqual, 2)
+			So(pages[35], ShouldEqual, 1)
+		})
+
+		Convey("TestPagedLoad BFSLoad and ConcurrentLoad", func() {
+			buildTreeForTest()
+			_, totalCount, err := newNewVirtualDirForTest().BFSLoad(nil, -1, -1, -1, nil, nil, nil,
+				WithPageRetrieve(newPagedMock(3, make(map[int64]int))))
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 19)
+			_, totalCount, err = newNewVirtualDirForTest().ConcurrentLoad(nil, 1, -1, -1, -1, nil, nil, nil,
+				WithPageRetrieve(newPagedMock(3, make(map[int64]int))))
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 19)
+		})
+
+		Convey("TestPagedLoad stop before fully fetched", func() {
+			buildBigFolderForTest()
+			dir := newNewVirtualDirForTest()
+			pages := make(map[int64]int)
+			_, _, err := dir.DFSLoad(nil, -1, 25, -1, nil, nil, nil, WithPageRetrieve(newPagedMock(10, pages)))
+			So(errors.Is(err, ErrFileNumLimit), ShouldBeTrue)
+			So(pages[0], ShouldEqual, 3)
+			So(dir.IsLoaded(), ShouldBeFalse)
+		})
+
+		Convey("TestPagedLoad TotalSizeLimit partial", func() {
+			buildBigFolderForTest()
+			dir := newNewVirtualDirForTest()
+			pages := make(map[int64]int)
+			report := &LoadReport{}
+			totalSize, totalCount, err := dir.DFSLoad(nil, -1, -1, 15, nil, nil, nil,
+				WithPageRetrieve(newPagedMock(10, pages)), WithPartialResult(report))
+			So(err, ShouldBeNil)
+			So(pages[0], ShouldEqual, 2)
+			So(totalCount, ShouldEqual, 20)
+			So(totalSize, ShouldEqual, 20)
+			So(dir.IsLoaded(), ShouldBeTrue)
+			So(len(dir.GetSubFiles()), ShouldEqual, 20)
+			So(errors.Is(dir.GetTruncatedReason(), ErrTotalSizeLimit), ShouldBeTrue)
+			So(len(report.Hits), ShouldEqual, 1)
+		})
+
+		Convey("TestPagedLoad retrieve error", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			errPage := errors.New("page failed")
+			failPage := func(ctx context.Context, volumeId, folderId int64, cursor string) (files, folders []*File, nextCursor string, err error) {
+				if cursor != "" {
+					return nil, nil, "", errPage
+				}
+				return nil, nil, "next", nil
+			}
+			_, _, err := dir.DFSLoad(nil, -1, -1, -1, nil, nil, nil, WithPageRetrieve(failPage))
+			So(errors.Is(err, errPage), ShouldBeTrue)
+		})
+
+		Convey("TestPagedLoad repeated cursor", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			calls := 0
+			stuckPage := func(ctx context.Context, volumeId, folderId int64, cursor string) (files, folders []*File, nextCursor string, err error) {
+				calls++
+				return nil, nil, "same", nil
+			}
+			_, _, err := dir.DFSLoad(nil, -1, -1, -1, nil, nil, nil, WithPageRetrieve(stuckPage))
+			So(errors.Is(err, ErrRepeatedCursor), ShouldBeTrue)
+			var dirErr *DirError
+			So(errors.As(err, &dirErr), ShouldBeTrue)
+			So(dirErr.FolderId, ShouldEqual, 0)
+			So(calls, ShouldEqual, 2)
+
+			//A->B->A循环
+			var cursors []string
+			cyclePage := func(ctx context.Context, volumeId, folderId int64, cursor string) (files, folders []*File, nextCursor string, err error) {
+				cursors = append(cursors, cursor)
+				if cursor == "A" {
+					return nil, nil, "B", nil
+				}
+				return nil, nil, "A", nil
+			}
+			_, _, err = newNewVirtualDirForTest().DFSLoad(nil, -1, -1, -1, nil, nil, nil, WithPageRetrieve(cyclePage))
+			So(errors.Is(err, ErrRepeatedCursor), ShouldBeTrue)
+			So(cursors, ShouldResemble, []string{"", "A", "B"})
+		})
+	})
+}
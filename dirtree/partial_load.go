@@ -0,0 +1,130 @@
+package dirtree
+
+import (
+	"context"
+	"fmt"
+)
+
+var (
+	errSkipSubtree = fmt.Errorf("skip subtree") //内部使用:跳过当前dir的子树,不执行postorderFunc,继续加载其他dir
+	errStopLoad    = fmt.Errorf("stop load")    //内部使用:停止整个加载过程,但不算出错
+)
+
+//LoadReport 部分加载模式下的加载报告
+type LoadReport struct {
+	Truncated  bool          //是否触发了限制导致没有加载完整
+	TotalSize  int64         //已加载的文件总大小
+	TotalCount int64         //已加载的文件(夹)总数
+	Hits       []*LimitError //每一次触发限制的位置
+}
+
+/*
+	WithPartialResult
+	部分加载模式:触发maxDepth,numLimit或sizeLimit时不返回错误,保留已经加载的数据,
+	并把被截断的Dir标记为truncated(可通过GetTruncatedReason查看原因,是*LimitError):
+	1.超过maxDepth的dir不加载,其他分支继续加载;
+	2.超过numLimit或sizeLimit时停止整个加载,触发限制的dir以及还没加载的dir都标记为truncated,
+	  之后的postorderFunc不再执行。
+	返回的totalSize和totalCount是已加载部分的统计,report不为nil时会填充触发限制的位置。
+	被截断而没有加载的dir在DfsWithFunc,BfsWithFunc,ConcurrentWalk以及各种迭代器和GetAll*中当作叶子节点,
+	所以可以直接遍历部分加载的结果。
+*/
+func WithPartialResult(report *LoadReport) LoadOption {
+	return func(opts *loadOptions) {
+		opts.partial = true
+		opts.report = report
+	}
+}
+
+//IsTruncated 部分加载模式下当前dir是否因为触发限制而没有完整加载
+func (d *Dir) IsTruncated() bool {
+	return d.truncated != nil
+}
+
+//GetTruncatedReason 当前dir被截断的原因(*LimitError),没有被截断时返回nil
+func (d *Dir) GetTruncatedReason() error {
+	return d.truncated
+}
+
+//traversable 遍历时是否可以访问dir:已经加载,或者被截断,被SkipDir跳过而没有加载(当作叶子节点)
+func (d *Dir) traversable() bool {
+	return d.loaded || d.truncated != nil || d.skipped
+}
+
+//limitHit 触发限制,非部分加载模式直接返回*LimitError,调用时需要持有info.mu
+func (info *dsfLoadInfo) limitHit(ctx context.Context, dir *Dir, limit error, max, totalCount, totalSize int64) error {
+	reason := &LimitError{
+		Limit:      limit,
+		Max:        max,
+		VolumeId:   dir.originInfo.VolumeId,
+		FolderId:   dir.originInfo.Id,
+		Depth:      dir.depth,
+		TotalCount: totalCount,
+		TotalSize:  totalSize,
+	}
+	info.logLimitHit(ctx, reason)
+	if !info.opts.partial {
+		return reason
+	}
+	dir.truncated = reason
+	info.hits = append(info.hits, reason)
+	if limit == ErrMaxPathDepthLimit {
+		return errSkipSubtree
+	}
+	if info.stopReason == nil {
+		info.stopReason = reason
+	}
+	return errStopLoad
+}
+
+//finish 处理加载结果,部分加载模式下停止加载不算出错
+func (info *dsfLoadInfo) finish(ctx context.Context, root *Dir, err error) (totalSize, totalCount int64, retErr error) {
+	root.recomputeTotals() //出错时已经加载的部分也保留在树上,累计统计同样需要更新
+	if err == errStopLoad {
+		root.markUnloaded(info.stopReason)
+		err = nil
+	}
+	if err == nil && info.opts.validate {
+		err = root.validateLoaded(ctx)
+	}
+	if err != nil {
+		info.logger.ErrorContext(ctx, "dirtree load finish",
+			"volumeId", root.originInfo.VolumeId,
+			"folderId", root.originInfo.Id,
+			"totalCount", info.totalCount,
+			"totalSize", info.totalSize,
+			"err", err)
+		return 0, 0, err
+	}
+	info.logger.InfoContext(ctx, "dirtree load finish",
+		"volumeId", root.originInfo.VolumeId,
+		"folderId", root.originInfo.Id,
+		"totalCount", info.totalCount,
+		"totalSize", info.totalSize,
+		"truncated", len(info.hits) > 0)
+	if report := info.opts.report; report != nil {
+		report.Truncated = len(info.hits) > 0
+		report.TotalSize = info.totalSize
+		report.TotalCount = info.totalCount
+		report.Hits = info.hits
+	}
+	return info.totalSize, info.totalCount, nil
+}
+
+//markUnloaded 停止加载后标记还没加载的dir:触发限制时标记为truncated,reason为nil(SkipAll停止加载)时标记为skipped,
+//被preorderFunc主动跳过的dir不再标记
+func (d *Dir) markUnloaded(reason error) {
+	_ = d.dfsStack(func(dir *Dir) error {
+		if dir.loaded {
+			return nil
+		}
+		if dir != d && dir.truncated == nil && !dir.skipped {
+			if reason != nil {
+				dir.truncated = reason
+			} else {
+				dir.skipped = true
+			}
+		}
+		return errSkipSubtree
+	}, nil)
+}
@@ -0,0 +1,144 @@
+package dirtree
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+//walkPartialForTest 用各种遍历方法遍历部分加载的结果,都不应该出错,并且都要访问到每个已加载的节点
+func walkPartialForTest(dir *Dir, totalSize, totalCount int64) {
+	files := dir.GetAllFoldersAndFiles(nil)
+	So(len(files), ShouldEqual, totalCount)
+	So(len(dir.GetAllFoldersAndFilesByBfs(nil)), ShouldEqual, totalCount)
+	var levelCount int64
+	for _, level := range dir.GetAllFoldersAndFilesOnLevel(nil) {
+		levelCount += int64(len(level))
+	}
+	So(levelCount, ShouldEqual, totalCount)
+	it := dir.IterDFS(nil)
+	var itCount int64
+	for it.Next() {
+		itCount++
+	}
+	So(it.Err(), ShouldBeNil)
+	So(itCount, ShouldEqual, totalCount)
+
+	size, count, err := dir.GetTotalSizeAndCount(nil)
+	So(err, ShouldBeNil)
+	So(size, ShouldEqual, totalSize)
+	So(count, ShouldEqual, totalCount)
+
+	//每个文件夹条目(包括被截断的)都作为dir访问到
+	var folderIds []int64
+	for _, file := range files {
+		if file.IsFolder() {
+			folderIds = append(folderIds, file.Id)
+		}
+	}
+	var preIds []int64
+	So(dir.DfsWithFunc(nil, func(ctx context.Context, d *Dir) error {
+		if d != dir {
+			preIds = append(preIds, d.GetId())
+		}
+		return nil
+	}, nil), ShouldBeNil)
+	sort.Slice(preIds, func(i, j int) bool { return preIds[i] < preIds[j] })
+	sort.Slice(folderIds, func(i, j int) bool { return folderIds[i] < folderIds[j] })
+	So(preIds, ShouldResemble, folderIds)
+	So(dir.BfsWithFunc(nil, nil), ShouldBeNil)
+	So(dir.DfsWithFileFunc(nil, nil, func(ctx context.Context, file *File, depth int64, parent *Dir) error {
+		return nil
+	}, nil), ShouldBeNil)
+	So(dir.ConcurrentWalk(nil, 4, nil, nil), ShouldBeNil)
+}
+
+func TestPartialLoad(t *testing.T) {
+	Convey("TestPartialLoad", t, func() {
+		Convey("TestPartialLoad DFSLoad FileNumLimit", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			report := &LoadReport{}
+			totalSize, totalCount, err := dir.DFSLoad(nil, -1, 12, -1, getSubFilesMock, nil, nil, WithPartialResult(report))
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 13)
+			So(totalSize, ShouldEqual, 8)
+			So(report.Truncated, ShouldBeTrue)
+			So(report.TotalCount, ShouldEqual, 13)
+			So(len(report.Hits), ShouldEqual, 1)
+			So(report.Hits[0].Limit, ShouldEqual, ErrFileNumLimit)
+			So(report.Hits[0].FolderId, ShouldEqual, 33)
+			So(report.Hits[0].Depth, ShouldEqual, 3)
+
+			dir12 := dir.GetSubDirs()[0]
+			dir13 := dir.GetSubDirs()[1]
+			dir22, dir23 := dir12.GetSubDirs()[0], dir12.GetSubDirs()[1]
+			dir33 := dir22.GetSubDirs()[0]
+			So(dir33.IsLoaded(), ShouldBeTrue)
+			So(errors.Is(dir33.GetTruncatedReason(), ErrFileNumLimit), ShouldBeTrue)
+			So(dir23.IsLoaded(), ShouldBeFalse)
+			So(errors.Is(dir23.GetTruncatedReason(), ErrFileNumLimit), ShouldBeTrue)
+			So(dir13.IsTruncated(), ShouldBeTrue)
+			So(dir22.IsTruncated(), ShouldBeFalse)
+			So(dir12.IsTruncated(), ShouldBeFalse)
+			walkPartialForTest(dir, totalSize, totalCount)
+		})
+
+		Convey("TestPartialLoad DFSLoad MaxDepthLimit", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			report := &LoadReport{}
+			totalSize, totalCount, err := dir.DFSLoad(nil, 3, -1, -1, getSubFilesMock, nil, nil, WithPartialResult(report))
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 17)
+			So(totalSize, ShouldEqual, 8)
+			So(len(report.Hits), ShouldEqual, 4)
+			for _, hit := range report.Hits {
+				So(hit.Limit, ShouldEqual, ErrMaxPathDepthLimit)
+				So(hit.Depth, ShouldEqual, 3)
+			}
+			walkPartialForTest(dir, totalSize, totalCount)
+		})
+
+		Convey("TestPartialLoad BFSLoad TotalSizeLimit", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			report := &LoadReport{}
+			totalSize, totalCount, err := dir.BFSLoad(nil, -1, -1, 5, getSubFilesMock, nil, nil, WithPartialResult(report))
+			So(err, ShouldBeNil)
+			So(totalSize, ShouldEqual, 7)
+			So(totalCount, ShouldEqual, 13)
+			So(len(report.Hits), ShouldEqual, 1)
+			So(report.Hits[0].Limit, ShouldEqual, ErrTotalSizeLimit)
+			So(report.Hits[0].FolderId, ShouldEqual, 22)
+			for _, subDir := range dir.GetSubDirs() {
+				So(subDir.IsLoaded(), ShouldBeTrue)
+				So(subDir.IsTruncated(), ShouldBeFalse)
+			}
+		})
+
+		Convey("TestPartialLoad ConcurrentLoad", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			report := &LoadReport{}
+			_, totalCount, err := dir.ConcurrentLoad(nil, 4, 3, -1, -1, getSubFilesMock, nil, nil, WithPartialResult(report))
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 17)
+			So(len(report.Hits), ShouldEqual, 4)
+		})
+
+		Convey("TestPartialLoad not truncated", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			report := &LoadReport{}
+			_, totalCount, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil, WithPartialResult(report))
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 19)
+			So(report.Truncated, ShouldBeFalse)
+			So(report.Hits, ShouldBeEmpty)
+		})
+	})
+}
@@ -0,0 +1,176 @@
+package dirtree
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"strings"
+)
+
+//PathOption 路径查找的选项
+type PathOption func(opts *pathOptions)
+
+type pathOptions struct {
+	caseInsensitive bool
+	normalize       func(name string) string
+	retrieve        RetrieveNextDepthFilesFunc
+}
+
+//WithCaseInsensitive 文件名比较时不区分大小写
+func WithCaseInsensitive() PathOption {
+	return func(opts *pathOptions) {
+		opts.caseInsensitive = true
+	}
+}
+
+//WithNormalize 比较之前先对文件名做Unicode规范化,如传入norm.NFC.String
+func WithNormalize(normalize func(name string) string) PathOption {
+	return func(opts *pathOptions) {
+		opts.normalize = normalize
+	}
+}
+
+//WithLazyLoad 中间层还没加载时通过retrieve加载,不指定时遇到没加载的dir返回ErrDirNotLoad
+func WithLazyLoad(retrieve RetrieveNextDepthFilesFunc) PathOption {
+	return func(opts *pathOptions) {
+		opts.retrieve = retrieve
+	}
+}
+
+func newPathOptions(opts []PathOption) *pathOptions {
+	options := &pathOptions{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(options)
+		}
+	}
+	return options
+}
+
+func (opts *pathOptions) normalizeName(name string) string {
+	if opts.normalize != nil {
+		name = opts.normalize(name)
+	}
+	return name
+}
+
+//match segment是已经规范化过的
+func (opts *pathOptions) match(name, segment string) bool {
+	name = opts.normalizeName(name)
+	if opts.caseInsensitive {
+		return strings.EqualFold(name, segment)
+	}
+	return name == segment
+}
+
+/*
+	Lookup
+	按"/"分隔的名字路径查找节点,路径相对于当前dir,如"/projects/2024/report.docx",空路径或"/"表示当前dir。
+	找到的是文件夹时返回dir,是文件时返回file,找不到时返回fs.ErrNotExist。
+	同名时文件夹优先。注意:WithLazyLoad会修改目录树,不能和其他读写并发调用。
+*/
+func (d *Dir) Lookup(ctx context.Context, namePath string, opts ...PathOption) (file *File, dir *Dir, err error) {
+	file, dir, err = d.lookupSegments(ctx, splitNamePath(namePath), newPathOptions(opts))
+	if err != nil {
+		return nil, nil, &fs.PathError{Op: "lookup", Path: namePath, Err: err}
+	}
+	return file, dir, nil
+}
+
+func splitNamePath(namePath string) []string {
+	var segments []string
+	for _, segment := range strings.Split(namePath, "/") {
+		if segment != "" {
+			segments = append(segments, segment)
+		}
+	}
+	return segments
+}
+
+func (d *Dir) lookupSegments(ctx context.Context, segments []string, opts *pathOptions) (*File, *Dir, error) {
+	dir := d
+	for i, segment := range segments {
+		if err := ctxErr(ctx); err != nil {
+			return nil, nil, err
+		}
+		if err := dir.lazyLoad(ctx, opts.retrieve); err != nil {
+			return nil, nil, err
+		}
+		segment = opts.normalizeName(segment)
+		var next *Dir
+		for _, subDir := range dir.subDirs {
+			if opts.match(subDir.originInfo.Name, segment) {
+				next = subDir
+				break
+			}
+		}
+		if next != nil {
+			dir = next
+			continue
+		}
+		if i == len(segments)-1 {
+			for _, file := range dir.subFiles {
+				if opts.match(file.Name, segment) {
+					return file, nil, nil
+				}
+			}
+		}
+		return nil, nil, fs.ErrNotExist
+	}
+	return nil, dir, nil
+}
+
+//lazyLoad 当前dir还没加载时通过retrieve加载一层
+func (d *Dir) lazyLoad(ctx context.Context, retrieve RetrieveNextDepthFilesFunc) error {
+	if d.loaded {
+		return nil
+	}
+	if retrieve == nil {
+		return newDirError("lookup", d, ErrDirNotLoad)
+	}
+	files, folders, err := retrieve(ctx, d.originInfo.VolumeId, d.originInfo.Id)
+	if err != nil {
+		return newDirError("retrieve", d, err)
+	}
+	return d.FillDirNoRecurse(ctx, files, folders)
+}
+
+//PathOf 已加载的节点(文件或文件夹)相对当前dir的完整路径,以"/"开头,当前dir自己是"/"。
+//开启了id索引时通过索引找到节点,否则按BFS遍历子树查找,再沿父目录指针向上拼出路径,
+//父目录指针回不到当前dir时(SafeTree的快照)改为从当前dir向下查找路径
+func (d *Dir) PathOf(ctx context.Context, id int64) (string, error) {
+	if d.originInfo.Id == id {
+		return "/", nil
+	}
+	file, _, parent, err := d.findNode(ctx, "path", id)
+	if errors.Is(err, ErrNodeNotFound) {
+		return "", fmt.Errorf("id=%d: %w", id, fs.ErrNotExist)
+	} else if err != nil {
+		return "", err
+	}
+	names := []string{file.Name}
+	for dir := parent; dir != d; dir = dir.parent {
+		if dir == nil { //父目录指针没有回到d(SafeTree快照中共享的子树),改为从d向下查找
+			return d.downPathOf(ctx, id)
+		}
+		names = append(names, dir.originInfo.Name)
+	}
+	for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+		names[i], names[j] = names[j], names[i]
+	}
+	return "/" + strings.Join(names, "/"), nil
+}
+
+//downPathOf 用从d向下查找到的路径拼出节点的完整路径
+func (d *Dir) downPathOf(ctx context.Context, id int64) (string, error) {
+	path, file, err := d.downPath(ctx, id)
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for _, dir := range path[1:] {
+		names = append(names, dir.originInfo.Name)
+	}
+	return "/" + strings.Join(append(names, file.Name), "/"), nil
+}
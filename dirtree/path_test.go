@@ -0,0 +1,118 @@
+package dirtree
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func newNamedDirForTest() *Dir {
+	files := []*File{
+		{Id: 1, ParentId: 0, VolumeId: 1, Name: "Projects", Type: typeFolder},
+		{Id: 2, ParentId: 1, VolumeId: 1, Name: "2024", Type: typeFolder},
+		{Id: 3, ParentId: 2, VolumeId: 1, Name: "Report.docx", Type: typeFile, Size: 10},
+		{Id: 4, ParentId: 1, VolumeId: 1, Name: "café", Type: typeFile, Size: 1}, //组合形式的é
+	}
+	dir, _, err := NewDirFromFiles(nil, 0, 1, files)
+	if err != nil {
+		panic(err)
+	}
+	return dir
+}
+
+func TestLookup(t *testing.T) {
+	Convey("TestLookup", t, func() {
+		Convey("TestLookup by name path", func() {
+			dir := newNamedDirForTest()
+			file, subDir, err := dir.Lookup(nil, "/Projects/2024/Report.docx")
+			So(err, ShouldBeNil)
+			So(subDir, ShouldBeNil)
+			So(file.Id, ShouldEqual, 3)
+
+			file, subDir, err = dir.Lookup(nil, "Projects/2024/")
+			So(err, ShouldBeNil)
+			So(file, ShouldBeNil)
+			So(subDir.GetId(), ShouldEqual, 2)
+
+			_, subDir, err = dir.Lookup(nil, "/")
+			So(err, ShouldBeNil)
+			So(subDir, ShouldEqual, dir)
+
+			_, _, err = dir.Lookup(nil, "/projects/2024/report.docx")
+			So(errors.Is(err, fs.ErrNotExist), ShouldBeTrue)
+			_, _, err = dir.Lookup(nil, "/Projects/2024/Report.docx/x")
+			So(errors.Is(err, fs.ErrNotExist), ShouldBeTrue)
+		})
+
+		Convey("TestLookup case insensitive and normalize", func() {
+			dir := newNamedDirForTest()
+			file, _, err := dir.Lookup(nil, "/projects/2024/REPORT.DOCX", WithCaseInsensitive())
+			So(err, ShouldBeNil)
+			So(file.Id, ShouldEqual, 3)
+
+			decomposed := "/Projects/café" //分解形式的é
+			_, _, err = dir.Lookup(nil, decomposed)
+			So(errors.Is(err, fs.ErrNotExist), ShouldBeTrue)
+			nfc := func(name string) string {
+				return strings.ReplaceAll(name, "é", "é")
+			}
+			file, _, err = dir.Lookup(nil, decomposed, WithNormalize(nfc))
+			So(err, ShouldBeNil)
+			So(file.Id, ShouldEqual, 4)
+		})
+
+		Convey("TestLookup lazy load", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			_, _, err := dir.Lookup(nil, "/0-12/12-22")
+			So(errors.Is(err, ErrDirNotLoad), ShouldBeTrue)
+
+			var retrieved []int64
+			retrieve := func(ctx context.Context, volumeId, folderId int64) (files, folders []*File, err error) {
+				retrieved = append(retrieved, folderId)
+				return getSubFilesMock(ctx, volumeId, folderId)
+			}
+			file, _, err := dir.Lookup(nil, "/0-12/12-22/22-33/33-41", WithLazyLoad(retrieve))
+			So(err, ShouldBeNil)
+			So(file.Id, ShouldEqual, 41)
+			So(retrieved, ShouldResemble, []int64{0, 12, 22, 33})
+			_, subDir, err := dir.Lookup(nil, "/0-12/12-22", WithLazyLoad(retrieve))
+			So(err, ShouldBeNil)
+			So(subDir.GetId(), ShouldEqual, 22)
+			So(len(retrieved), ShouldEqual, 4)
+		})
+	})
+}
+
+func TestPathOf(t *testing.T) {
+	Convey("TestPathOf", t, func() {
+		dir := newNamedDirForTest()
+		namePath, err := dir.PathOf(nil, 3)
+		So(err, ShouldBeNil)
+		So(namePath, ShouldEqual, "/Projects/2024/Report.docx")
+		namePath, err = dir.PathOf(nil, 2)
+		So(err, ShouldBeNil)
+		So(namePath, ShouldEqual, "/Projects/2024")
+		namePath, err = dir.PathOf(nil, 0)
+		So(err, ShouldBeNil)
+		So(namePath, ShouldEqual, "/")
+		_, err = dir.PathOf(nil, 99)
+		So(errors.Is(err, fs.ErrNotExist), ShouldBeTrue)
+
+		//PathOf和Lookup互逆
+		for _, file := range dir.GetAllFoldersAndFiles(nil) {
+			namePath, err := dir.PathOf(nil, file.Id)
+			So(err, ShouldBeNil)
+			found, subDir, err := dir.Lookup(nil, namePath)
+			So(err, ShouldBeNil)
+			if subDir != nil {
+				found = subDir.GetDirOriginInfo()
+			}
+			So(found, ShouldEqual, file)
+		}
+	})
+}
@@ -0,0 +1,338 @@
+package dirtree
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+/*
+	SafeTree
+	并发安全的目录树,读多写少的场景下使用(比如多个请求共享缓存的目录树,后台定时刷新)。
+	1.Snapshot返回当前版本的根节点,读取不需要加锁,拿到的快照不会再被修改,之后的写入对它不可见;
+	2.Update串行执行写操作,所有修改都在Tx的副本上进行,fn返回nil之后原子发布为新版本,返回错误时丢弃全部修改;
+	3.写时复制:只复制从根到被修改节点的路径(移动文件夹并且层级变化时还要复制被移动的子树),其它子树在新旧版本之间共享,
+	  所以每次写入的复制成本和修改路径的长度成正比,而不是和整棵树的大小成正比。
+	注意:
+	快照是只读的,不能调用FillDirNoRecurse,加载,AddNode等修改方法,也不能用WithLazyLoad查找路径;
+	共享子树里面的Parent()(以及Ancestors,Root,Siblings,Path等沿父目录向上的方法)可能指向旧版本的节点,
+	快照上需要用快照的根的ParentOf,AncestorsOf,SiblingsOf,PathOf从根向下查找(或者用迭代器的Parent());
+	SafeTree不支持id索引(NewSafeTree会关闭传入的树上的索引),写入方自己维护每个节点的父目录id,
+	Tx按id定位节点时先沿父目录id找到根,再从根向下只查找路径上的dir,不需要遍历整棵树。
+*/
+type SafeTree struct {
+	mu      sync.Mutex      //串行化写操作
+	root    atomic.Value    //*Dir,当前版本的根节点
+	parents map[int64]int64 //当前版本每个节点(根节点除外)的父目录id,只在写锁内使用
+}
+
+//NewSafeTree 用已经加载的树新建SafeTree,root之后归SafeTree所有,不能再直接修改。
+//NewSafeTree会遍历一次整棵树,并且直接关闭root所在的树上的id索引(之后GetIndex都返回nil),而不是复制一份
+func NewSafeTree(root *Dir) *SafeTree {
+	t := &SafeTree{parents: make(map[int64]int64)}
+	_ = root.dfsStack(func(dir *Dir) error {
+		dir.index = nil
+		for _, subDir := range dir.subDirs {
+			t.parents[subDir.originInfo.Id] = dir.originInfo.Id
+		}
+		for _, file := range dir.subFiles {
+			t.parents[file.Id] = dir.originInfo.Id
+		}
+		return nil
+	}, nil)
+	t.root.Store(root)
+	return t
+}
+
+//Snapshot 当前版本的只读快照
+func (t *SafeTree) Snapshot() *Dir {
+	return t.root.Load().(*Dir)
+}
+
+//Update 在当前版本的基础上执行fn,fn返回nil时原子发布修改之后的版本,返回错误时丢弃修改并返回这个错误
+func (t *SafeTree) Update(fn func(tx *Tx) error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tx := &Tx{
+		root:       t.Snapshot(),
+		ownedDirs:  make(map[*Dir]bool),
+		ownedFiles: make(map[*File]bool),
+		parents:    t.parents,
+		changes:    make(map[int64]parentChange),
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+	for id, change := range tx.changes {
+		if change.removed {
+			delete(t.parents, id)
+		} else {
+			t.parents[id] = change.parentId
+		}
+	}
+	t.root.Store(tx.root)
+	return nil
+}
+
+/*
+	Tx
+	SafeTree.Update中的一次写操作,修改方法和Dir上的同名方法含义相同,只是修改前先复制要修改的路径。
+	Tx只在fn内部有效,不是并发安全的。
+*/
+type Tx struct {
+	root       *Dir
+	ownedDirs  map[*Dir]bool          //这次Update复制出来的dir,可以直接修改
+	ownedFiles map[*File]bool         //这次Update复制出来的File,可以直接修改
+	parents    map[int64]int64        //SafeTree.parents,Tx里面只读
+	changes    map[int64]parentChange //这次Update对parents的修改,fn成功之后才合并到SafeTree.parents
+}
+
+//parentChange 节点的父目录id的变化,removed表示节点被删除
+type parentChange struct {
+	parentId int64
+	removed  bool
+}
+
+//Root 正在修改的版本的根节点,只能读取,修改需要通过Tx的方法
+func (tx *Tx) Root() *Dir {
+	return tx.root
+}
+
+//FillDirNoRecurse 填充id对应的还没有加载的dir
+func (tx *Tx) FillDirNoRecurse(ctx context.Context, id int64, subFiles, subFolders []*File) error {
+	_, dir, _, err := tx.own(ctx, "fill", id)
+	if err != nil {
+		return err
+	}
+	if dir == nil {
+		return fmt.Errorf("dirtree fill id=%d: %w", id, ErrNotFolderType)
+	}
+	if err = dir.FillDirNoRecurse(ctx, subFiles, subFolders); err != nil {
+		return err
+	}
+	for _, file := range dir.GetSubFoldersAndFiles() {
+		tx.setParent(file.Id, id)
+	}
+	return nil
+}
+
+//AddNode 见Dir.AddNode
+func (tx *Tx) AddNode(ctx context.Context, parentId int64, file *File) error {
+	_, parent, _, err := tx.own(ctx, "add", parentId)
+	if err != nil {
+		return err
+	}
+	if parent == nil {
+		return fmt.Errorf("dirtree add id=%d: %w", parentId, ErrNotFolderType)
+	}
+	if err = parent.canAdd(file); err != nil {
+		return err
+	}
+	if _, ok := tx.parentId(file.Id); ok || file.Id == tx.root.originInfo.Id {
+		return fmt.Errorf("dirtree add id=%d: %w", file.Id, ErrDuplicateId)
+	}
+	parent.addChild(file, true)
+	tx.setParent(file.Id, parentId)
+	return nil
+}
+
+//RemoveNode 见Dir.RemoveNode
+func (tx *Tx) RemoveNode(ctx context.Context, id int64) (*File, error) {
+	file, dir, parent, err := tx.own(ctx, "remove", id)
+	if err != nil {
+		return nil, err
+	}
+	if parent == nil {
+		return nil, fmt.Errorf("dirtree remove id=%d: %w", id, ErrModifyRoot)
+	}
+	parent.detach(file, dir)
+	tx.changes[id] = parentChange{removed: true}
+	if dir != nil {
+		_ = dir.dfsStack(func(subDir *Dir) error {
+			for _, file := range subDir.GetSubFoldersAndFiles() {
+				tx.changes[file.Id] = parentChange{removed: true}
+			}
+			return nil
+		}, nil)
+	}
+	return file, nil
+}
+
+//MoveNode 见Dir.MoveNode,移动文件夹并且层级变化时先复制整个被移动的子树,之后只修改复制出来的节点的depth
+func (tx *Tx) MoveNode(ctx context.Context, id, newParentId int64) error {
+	file, dir, oldParent, err := tx.own(ctx, "move", id)
+	if err != nil {
+		return err
+	}
+	if oldParent == nil {
+		return fmt.Errorf("dirtree move id=%d: %w", id, ErrModifyRoot)
+	}
+	_, newParent, _, err := tx.own(ctx, "move", newParentId)
+	if err != nil {
+		return err
+	}
+	if newParent == nil {
+		return fmt.Errorf("dirtree move id=%d: %w", newParentId, ErrNotFolderType)
+	}
+	if err = newParent.canMoveIn(file, dir); err != nil {
+		return err
+	}
+	if dir != nil && newParent.depth+1 != dir.depth {
+		tx.ownSubtree(dir)
+	}
+	oldParent.moveChild(file, dir, newParent)
+	tx.setParent(id, newParentId)
+	return nil
+}
+
+//UpdateNode 见Dir.UpdateNode
+func (tx *Tx) UpdateNode(ctx context.Context, id int64, info *File) error {
+	file, dir, parent, err := tx.own(ctx, "update", id)
+	if err != nil {
+		return err
+	}
+	updateFile(file, dir, parent, info)
+	return nil
+}
+
+//RenameNode 见Dir.RenameNode
+func (tx *Tx) RenameNode(ctx context.Context, id int64, name string) error {
+	file, _, _, err := tx.own(ctx, "rename", id)
+	if err != nil {
+		return err
+	}
+	file.Name = name
+	return nil
+}
+
+//parentId 当前版本中id对应节点的父目录id,根节点或者不在树上时返回false
+func (tx *Tx) parentId(id int64) (int64, bool) {
+	if change, ok := tx.changes[id]; ok {
+		return change.parentId, !change.removed
+	}
+	parentId, ok := tx.parents[id]
+	return parentId, ok
+}
+
+func (tx *Tx) setParent(id, parentId int64) {
+	tx.changes[id] = parentChange{parentId: parentId}
+}
+
+//own 复制从根到id对应节点的路径以及节点本身,返回复制出来的节点(parent是它所在的dir),之后可以直接修改它们
+func (tx *Tx) own(ctx context.Context, op string, id int64) (file *File, dir *Dir, parent *Dir, err error) {
+	if err = ctxErr(ctx); err != nil {
+		return nil, nil, nil, err
+	}
+	path, isFile, err := tx.findPath(op, id)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, pathDir := range path {
+		if !tx.ownedDirs[pathDir] {
+			pathDir = tx.cloneDir(pathDir, parent)
+		}
+		parent = pathDir
+	}
+	if !isFile {
+		dir = parent
+		return dir.originInfo, dir, dir.parent, nil
+	}
+	for i, subFile := range parent.subFiles {
+		if subFile.Id != id {
+			continue
+		}
+		if !tx.ownedFiles[subFile] {
+			newFile := *subFile
+			parent.subFiles[i] = &newFile
+			tx.ownedFiles[&newFile] = true
+			subFile = &newFile
+		}
+		return subFile, nil, parent, nil
+	}
+	return nil, nil, nil, fmt.Errorf("dirtree %s id=%d: %w", op, id, ErrNodeNotFound)
+}
+
+//findPath 从根到id对应节点的路径,id是文件时路径的最后一个是它所在的dir。
+//先沿父目录id向上找到根,再从根向下逐层匹配子目录,代价和路径的长度成正比,不需要遍历整棵树
+func (tx *Tx) findPath(op string, id int64) (path []*Dir, isFile bool, err error) {
+	rootId := tx.root.originInfo.Id
+	var ancestorIds []int64 //从父目录到根
+	for curr := id; curr != rootId; {
+		parentId, ok := tx.parentId(curr)
+		if !ok {
+			return nil, false, fmt.Errorf("dirtree %s id=%d: %w", op, id, ErrNodeNotFound)
+		}
+		ancestorIds = append(ancestorIds, parentId)
+		curr = parentId
+	}
+	dir := tx.root
+	path = []*Dir{dir}
+	for i := len(ancestorIds) - 2; i >= 0; i-- {
+		if dir = subDirById(dir, ancestorIds[i]); dir == nil {
+			return nil, false, fmt.Errorf("dirtree %s id=%d: %w", op, id, ErrNodeNotFound)
+		}
+		path = append(path, dir)
+	}
+	if id == rootId {
+		return path, false, nil
+	}
+	if subDir := subDirById(dir, id); subDir != nil {
+		return append(path, subDir), false, nil
+	}
+	for _, file := range dir.subFiles {
+		if file.Id == id {
+			return path, true, nil
+		}
+	}
+	return nil, false, fmt.Errorf("dirtree %s id=%d: %w", op, id, ErrNodeNotFound)
+}
+
+//subDirById dir的直接子目录中id对应的那个,没有时返回nil
+func subDirById(dir *Dir, id int64) *Dir {
+	for _, subDir := range dir.subDirs {
+		if subDir.originInfo.Id == id {
+			return subDir
+		}
+	}
+	return nil
+}
+
+//ownSubtree 复制dir下面的整个子树,dir本身需要已经复制
+func (tx *Tx) ownSubtree(dir *Dir) {
+	_ = dir.dfsStack(func(parent *Dir) error {
+		for _, subDir := range parent.subDirs {
+			if !tx.ownedDirs[subDir] {
+				tx.cloneDir(subDir, parent)
+			}
+		}
+		return nil
+	}, nil)
+}
+
+//cloneDir 复制dir(包括originInfo,子节点切片和累计统计),替换parent中对应的子目录,parent为nil时作为新的根
+func (tx *Tx) cloneDir(dir, parent *Dir) *Dir {
+	newDir := *dir
+	info := *dir.originInfo
+	newDir.originInfo = &info
+	newDir.subDirs = append([]*Dir(nil), dir.subDirs...)
+	newDir.subFiles = append([]*File(nil), dir.subFiles...)
+	newDir.totals = &dirTotals{}
+	newDir.totals.store(dir.totals.load())
+	newDir.index = nil
+	newDir.parent = parent
+	tx.ownedDirs[&newDir] = true
+	tx.ownedFiles[&info] = true
+
+	if parent == nil {
+		tx.root = &newDir
+		return &newDir
+	}
+	for i, subDir := range parent.subDirs {
+		if subDir == dir {
+			parent.subDirs[i] = &newDir
+			break
+		}
+	}
+	return &newDir
+}
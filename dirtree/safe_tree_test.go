@@ -0,0 +1,277 @@
+package dirtree
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSafeTree(t *testing.T) {
+	Convey("TestSafeTree", t, func() {
+		buildTreeForTest()
+		dir := newNewVirtualDirForTest()
+		dir.EnableIndex()
+		_, _, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil)
+		So(err, ShouldBeNil)
+		tree := NewSafeTree(dir)
+		So(dir.GetIndex(), ShouldBeNil)
+		old := tree.Snapshot()
+		oldIds := fileIds(old.GetAllFoldersAndFiles(nil))
+
+		Convey("TestSafeTree update copies changed path", func() {
+			err := tree.Update(func(tx *Tx) error {
+				if err := tx.AddNode(nil, 22, &File{Id: 50, VolumeId: 1, Type: typeFile, Size: 5}); err != nil {
+					return err
+				}
+				if err := tx.UpdateNode(nil, 41, &File{Name: "41", Version: 2, Size: 3}); err != nil {
+					return err
+				}
+				return tx.RenameNode(nil, 33, "renamed")
+			})
+			So(err, ShouldBeNil)
+			snap := tree.Snapshot()
+			So(snap, ShouldNotEqual, old)
+			So(snap.GetTotalSize(), ShouldEqual, 17)
+			So(snap.GetTotalFileCount(), ShouldEqual, 11)
+			snap33 := snap.GetSubDirs()[0].GetSubDirs()[0].GetSubDirs()[0]
+			So(snap33.GetDirOriginInfo().Name, ShouldEqual, "renamed")
+			So(snap33.GetSubFiles()[0].Size, ShouldEqual, 3)
+
+			//旧快照不变
+			So(fileIds(old.GetAllFoldersAndFiles(nil)), ShouldResemble, oldIds)
+			So(old.GetTotalSize(), ShouldEqual, 10)
+			old33 := old.GetSubDirs()[0].GetSubDirs()[0].GetSubDirs()[0]
+			So(old33.GetDirOriginInfo().Name, ShouldEqual, "22-33")
+			So(old33.GetSubFiles()[0].Size, ShouldEqual, 1)
+			So(old33.GetTotalSize(), ShouldEqual, 1)
+
+			//没有修改的子树共享
+			So(snap.GetSubDirs()[1], ShouldEqual, old.GetSubDirs()[1])
+			So(snap.GetSubDirs()[0].GetSubDirs()[1], ShouldEqual, old.GetSubDirs()[0].GetSubDirs()[1])
+			So(snap.GetSubFiles()[0], ShouldEqual, old.GetSubFiles()[0])
+		})
+
+		Convey("TestSafeTree move and remove", func() {
+			err := tree.Update(func(tx *Tx) error {
+				if err := tx.MoveNode(nil, 22, 37); err != nil {
+					return err
+				}
+				_, err := tx.RemoveNode(nil, 10)
+				return err
+			})
+			So(err, ShouldBeNil)
+			snap := tree.Snapshot()
+			snap37 := snap.GetSubDirs()[1].GetSubDirs()[0].GetSubDirs()[0]
+			snap22 := snap37.GetSubDirs()[0]
+			So(snap22.GetId(), ShouldEqual, 22)
+			So(snap22.Parent(), ShouldEqual, snap37)
+			So(snap22.GetDepth(), ShouldEqual, 4)
+			So(snap22.GetSubDirs()[0].GetDepth(), ShouldEqual, 5)
+			So(snap.GetCount(), ShouldEqual, 3)
+			So(snap37.GetTotalSize(), ShouldEqual, 5)
+
+			old22 := old.GetSubDirs()[0].GetSubDirs()[0]
+			So(old22.GetDepth(), ShouldEqual, 2)
+			So(old22.GetSubDirs()[0].GetDepth(), ShouldEqual, 3)
+			So(old22.GetDirOriginInfo().ParentId, ShouldEqual, 12)
+			So(old.GetCount(), ShouldEqual, 4)
+			So(fileIds(old.GetAllFoldersAndFiles(nil)), ShouldResemble, oldIds)
+		})
+
+		Convey("TestSafeTree snapshot navigation", func() {
+			So(tree.Update(func(tx *Tx) error {
+				return tx.RenameNode(nil, 12, "renamed")
+			}), ShouldBeNil)
+			snap := tree.Snapshot()
+			snap12 := snap.GetSubDirs()[0]
+			snap22 := snap12.GetSubDirs()[0]
+			snap33 := snap22.GetSubDirs()[0]
+			So(snap22.Parent(), ShouldNotEqual, snap12) //共享的子树指向旧版本的父目录
+
+			parent, err := snap.ParentOf(nil, 22)
+			So(err, ShouldBeNil)
+			So(parent, ShouldEqual, snap12)
+			ancestors, err := snap.AncestorsOf(nil, 33)
+			So(err, ShouldBeNil)
+			So(ancestors, ShouldResemble, []*Dir{snap22, snap12, snap})
+			siblings, err := snap.SiblingsOf(nil, 22)
+			So(err, ShouldBeNil)
+			So(siblings, ShouldResemble, snap12.GetSubDirs()[1:])
+			namePath, err := snap.PathOf(nil, 33)
+			So(err, ShouldBeNil)
+			So(namePath, ShouldEqual, "/renamed/"+snap22.GetDirOriginInfo().Name+"/"+snap33.GetDirOriginInfo().Name)
+
+			parent, err = snap.ParentOf(nil, snap.GetId())
+			So(err, ShouldBeNil)
+			So(parent, ShouldBeNil)
+			_, err = snap.AncestorsOf(nil, 99)
+			So(errors.Is(err, ErrNodeNotFound), ShouldBeTrue)
+		})
+
+		Convey("TestSafeTree update error discards changes", func() {
+			errTest := errors.New("test")
+			err := tree.Update(func(tx *Tx) error {
+				if err := tx.RenameNode(nil, 33, "renamed"); err != nil {
+					return err
+				}
+				return errTest
+			})
+			So(err, ShouldEqual, errTest)
+			So(tree.Snapshot(), ShouldEqual, old)
+
+			err = tree.Update(func(tx *Tx) error {
+				return tx.RenameNode(nil, 99, "x")
+			})
+			So(errors.Is(err, ErrNodeNotFound), ShouldBeTrue)
+			So(tree.Snapshot(), ShouldEqual, old)
+		})
+
+		Convey("TestSafeTree locate nodes after earlier updates", func() {
+			errTest := errors.New("test")
+			err := tree.Update(func(tx *Tx) error {
+				if err := tx.AddNode(nil, 22, &File{Id: 50, VolumeId: 1, Type: typeFolder}); err != nil {
+					return err
+				}
+				return errTest
+			})
+			So(err, ShouldEqual, errTest)
+			err = tree.Update(func(tx *Tx) error {
+				return tx.RenameNode(nil, 50, "x")
+			})
+			So(errors.Is(err, ErrNodeNotFound), ShouldBeTrue) //失败的Update不影响之后的查找
+
+			err = tree.Update(func(tx *Tx) error {
+				if err := tx.AddNode(nil, 22, &File{Id: 50, VolumeId: 1, Type: typeFolder, Name: "new"}); err != nil {
+					return err
+				}
+				if err := tx.AddNode(nil, 50, &File{Id: 51, VolumeId: 1, Type: typeFile, Size: 2}); err != nil {
+					return err
+				}
+				return tx.MoveNode(nil, 50, 13)
+			})
+			So(err, ShouldBeNil)
+			err = tree.Update(func(tx *Tx) error {
+				return tx.AddNode(nil, 13, &File{Id: 51, VolumeId: 1, Type: typeFile})
+			})
+			So(errors.Is(err, ErrDuplicateId), ShouldBeTrue)
+			err = tree.Update(func(tx *Tx) error {
+				return tx.UpdateNode(nil, 51, &File{Name: "51", Size: 3})
+			})
+			So(err, ShouldBeNil)
+			namePath, err := tree.Snapshot().PathOf(nil, 51)
+			So(err, ShouldBeNil)
+			So(namePath, ShouldEqual, "/"+tree.Snapshot().GetSubDirs()[1].GetDirOriginInfo().Name+"/new/51")
+			So(tree.Snapshot().GetTotalSize(), ShouldEqual, 13)
+
+			//删除文件夹之后它的子孙id可以重新使用
+			err = tree.Update(func(tx *Tx) error {
+				if _, err := tx.RemoveNode(nil, 22); err != nil {
+					return err
+				}
+				return tx.AddNode(nil, 13, &File{Id: 33, VolumeId: 1, Type: typeFile, Size: 1})
+			})
+			So(err, ShouldBeNil)
+			err = tree.Update(func(tx *Tx) error {
+				return tx.RenameNode(nil, 41, "x")
+			})
+			So(errors.Is(err, ErrNodeNotFound), ShouldBeTrue)
+			violations, err := tree.Snapshot().Validate(nil)
+			So(err, ShouldBeNil)
+			So(violations, ShouldBeNil)
+		})
+
+		Convey("TestSafeTree fill unloaded dir", func() {
+			buildTreeForTest()
+			partial := newNewVirtualDirForTest()
+			var preIds []int64
+			_, _, err := partial.DFSLoad(nil, -1, -1, -1, getSubFilesMock, skipDirFunc(22, SkipDir, &preIds), nil)
+			So(err, ShouldBeNil)
+			tree = NewSafeTree(partial)
+			err = tree.Update(func(tx *Tx) error {
+				files, folders, err := getSubFilesMock(nil, 1, 22)
+				if err != nil {
+					return err
+				}
+				return tx.FillDirNoRecurse(nil, 22, files, folders)
+			})
+			So(err, ShouldBeNil)
+			So(tree.Snapshot().GetSubDirs()[0].GetSubDirs()[0].IsLoaded(), ShouldBeTrue)
+			So(tree.Snapshot().GetTotalFileCount(), ShouldEqual, 9)
+			So(partial.GetSubDirs()[0].GetSubDirs()[0].IsLoaded(), ShouldBeFalse)
+			So(partial.GetTotalFileCount(), ShouldEqual, 6)
+		})
+
+		Convey("TestSafeTree concurrent read and write", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < 50; j++ {
+						snap := tree.Snapshot()
+						var size int64
+						for _, file := range snap.GetAllPureFiles(nil) {
+							size += file.Size
+						}
+						if size != snap.GetTotalSize() {
+							panic("inconsistent snapshot")
+						}
+					}
+				}()
+			}
+			for i := int64(0); i < 50; i++ {
+				id := 100 + i
+				So(tree.Update(func(tx *Tx) error {
+					return tx.AddNode(nil, 33, &File{Id: id, VolumeId: 1, Type: typeFile, Size: 1})
+				}), ShouldBeNil)
+			}
+			wg.Wait()
+			So(tree.Snapshot().GetTotalSize(), ShouldEqual, 60)
+			So(old.GetTotalSize(), ShouldEqual, 10)
+		})
+
+		Convey("TestSafeTree concurrent move folder", func() {
+			var wg sync.WaitGroup
+			reading := make(chan struct{}) //读取的goroutine拿到快照之后通知写入,保证写入时有正在遍历的快照
+			done := make(chan struct{})
+			for i := 0; i < 4; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for {
+						snap := tree.Snapshot()
+						select {
+						case reading <- struct{}{}:
+						case <-done:
+							return
+						}
+						it := snap.IterDFS(nil)
+						for it.Next() {
+							if dir := it.Dir(); dir != nil && dir.GetDepth() != it.Depth() {
+								panic("inconsistent depth")
+							}
+						}
+					}
+				}()
+			}
+			for i := 0; i < 20; i++ {
+				for _, parentId := range []int64{13, 12, 37, 12, 23, 12} { //同层移动和跨层移动
+					parentId := parentId
+					<-reading
+					So(tree.Update(func(tx *Tx) error {
+						return tx.MoveNode(nil, 22, parentId)
+					}), ShouldBeNil)
+				}
+			}
+			close(done)
+			wg.Wait()
+			snap22 := tree.Snapshot().GetSubDirs()[0].GetSubDirs()[1]
+			So(snap22.GetId(), ShouldEqual, 22)
+			So(snap22.GetSubDirs()[0].GetDepth(), ShouldEqual, 3)
+			So(len(tree.Snapshot().GetAllFoldersAndFiles(nil)), ShouldEqual, len(oldIds))
+			So(fileIds(old.GetAllFoldersAndFiles(nil)), ShouldResemble, oldIds)
+		})
+	})
+}
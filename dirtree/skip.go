@@ -0,0 +1,59 @@
+package dirtree
+
+import (
+	"context"
+	"errors"
+)
+
+/*
+	SkipDir 和 SkipAll
+	DirFunc返回这两个值时不算出错,用法和fs.SkipDir,fs.SkipAll一样:
+	1.DfsWithFunc:preorderFunc返回SkipDir时跳过当前dir的子树,并且不再调用当前dir的postorderFunc;
+	  postorderFunc返回SkipDir等同于返回nil;任意一个返回SkipAll时停止遍历,DfsWithFunc返回nil。
+	2.BfsWithFunc:callBack返回SkipDir时不再遍历当前dir的子目录,返回SkipAll时停止遍历并返回nil。
+	3.DFSLoad,BFSLoad,ConcurrentLoad,BatchLoad:preorderFunc返回SkipDir时不拉取当前dir的数据,
+	  当前dir保持未加载状态(不标记为truncated,IsSkipped返回true),其他dir继续加载;返回SkipAll时停止加载,
+	  保留已经加载的数据并返回已加载部分的统计,还没加载的dir都标记为被跳过。postorderFunc返回值的含义同DfsWithFunc。
+	  加载之后各种遍历方法和迭代器把被跳过的dir当作叶子节点,不会返回ErrDirNotLoad。
+*/
+var (
+	SkipDir = errors.New("skip this dir")
+	SkipAll = errors.New("skip everything and stop the walk")
+)
+
+//skipSubtree 把preorderFunc的返回值转换成内部使用的错误
+func skipSubtree(err error) error {
+	switch err {
+	case SkipDir:
+		return errSkipSubtree
+	case SkipAll:
+		return errStopLoad
+	}
+	return err
+}
+
+//skipPostorder 把postorderFunc的返回值转换成内部使用的错误,SkipDir等同于nil
+func skipPostorder(err error) error {
+	switch err {
+	case SkipDir:
+		return nil
+	case SkipAll:
+		return errStopLoad
+	}
+	return err
+}
+
+//skipPostorderFunc 包装postorderFunc,按skipPostorder转换返回值
+func skipPostorderFunc(postorderFunc DirFunc) DirFunc {
+	if postorderFunc == nil {
+		return nil
+	}
+	return func(ctx context.Context, dir *Dir) error {
+		return skipPostorder(postorderFunc(ctx, dir))
+	}
+}
+
+//IsSkipped 加载时当前dir是否因为preorderFunc返回SkipDir(或者SkipAll停止加载)而没有加载,被跳过的dir不会标记为truncated,遍历时当作叶子节点
+func (d *Dir) IsSkipped() bool {
+	return d.skipped && !d.loaded
+}
@@ -0,0 +1,254 @@
+package dirtree
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+//skipDirFunc 遇到id时返回ret,同时按调用顺序记录dir的id
+//unloadedDirForTest 加载整棵树,但22没有加载,也不是被跳过或者截断的dir,遍历到22时返回ErrDirNotLoad
+func unloadedDirForTest() *Dir {
+	buildTreeForTest()
+	dir := newNewVirtualDirForTest()
+	var preIds []int64
+	if _, _, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, skipDirFunc(22, SkipDir, &preIds), nil); err != nil {
+		panic(err)
+	}
+	dir.GetSubDirs()[0].GetSubDirs()[0].skipped = false
+	return dir
+}
+
+func skipDirFunc(id int64, ret error, ids *[]int64) DirFunc {
+	var mu sync.Mutex
+	return func(ctx context.Context, dir *Dir) error {
+		mu.Lock()
+		defer mu.Unlock()
+		*ids = append(*ids, dir.GetId())
+		if dir.GetId() == id {
+			return ret
+		}
+		return nil
+	}
+}
+
+func TestSkip(t *testing.T) {
+	Convey("TestSkip", t, func() {
+		Convey("TestSkip DfsWithFunc", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			_, _, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil)
+			So(err, ShouldBeNil)
+
+			var preIds, postIds []int64
+			err = dir.DfsWithFunc(nil, skipDirFunc(22, SkipDir, &preIds), skipDirFunc(23, SkipDir, &postIds))
+			So(err, ShouldBeNil)
+			So(preIds, ShouldResemble, []int64{0, 12, 22, 23, 35, 36, 13, 24, 37})
+			So(postIds, ShouldResemble, []int64{35, 36, 23, 12, 37, 24, 13, 0})
+
+			preIds, postIds = nil, nil
+			err = dir.DfsWithFunc(nil, skipDirFunc(23, SkipAll, &preIds), skipDirFunc(-1, nil, &postIds))
+			So(err, ShouldBeNil)
+			So(preIds, ShouldResemble, []int64{0, 12, 22, 33, 23})
+			So(postIds, ShouldResemble, []int64{33, 22})
+
+			postIds = nil
+			err = dir.DfsWithFunc(nil, nil, skipDirFunc(22, SkipAll, &postIds))
+			So(err, ShouldBeNil)
+			So(postIds, ShouldResemble, []int64{33, 22})
+		})
+
+		Convey("TestSkip BfsWithFunc", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			_, _, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil)
+			So(err, ShouldBeNil)
+
+			var ids []int64
+			So(dir.BfsWithFunc(nil, skipDirFunc(12, SkipDir, &ids)), ShouldBeNil)
+			So(ids, ShouldResemble, []int64{0, 12, 13, 24, 37})
+
+			ids = nil
+			So(dir.BfsWithFunc(nil, skipDirFunc(23, SkipAll, &ids)), ShouldBeNil)
+			So(ids, ShouldResemble, []int64{0, 12, 13, 22, 23})
+		})
+
+		Convey("TestSkip DFSLoad SkipDir", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			var preIds, postIds []int64
+			totalSize, totalCount, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock,
+				skipDirFunc(22, SkipDir, &preIds), skipDirFunc(-1, nil, &postIds))
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 14)
+			So(totalSize, ShouldEqual, 6)
+			So(postIds, ShouldResemble, []int64{35, 36, 23, 12, 37, 24, 13, 0})
+
+			dir22 := dir.GetSubDirs()[0].GetSubDirs()[0]
+			So(dir22.GetId(), ShouldEqual, 22)
+			So(dir22.IsLoaded(), ShouldBeFalse)
+			So(dir22.IsTruncated(), ShouldBeFalse)
+		})
+
+		Convey("TestSkip walk after SkipDir load", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			var preIds []int64
+			_, totalCount, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, skipDirFunc(12, SkipDir, &preIds), nil)
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 7)
+			dir12 := dir.GetSubDirs()[0]
+			So(dir12.IsSkipped(), ShouldBeTrue)
+			So(dir.GetSubDirs()[1].IsSkipped(), ShouldBeFalse)
+
+			So(fileIds(dir.GetAllFoldersAndFiles(nil)), ShouldResemble, []int64{12, 13, 10, 11, 24, 37, 42})
+			So(fileIds(dir.GetAllPureFiles(nil)), ShouldResemble, []int64{10, 11, 42})
+			size, count, err := dir.GetTotalSizeAndCount(nil)
+			So(err, ShouldBeNil)
+			So(size, ShouldEqual, 3)
+			So(count, ShouldEqual, 7)
+
+			var ids []int64
+			So(dir.DfsWithFunc(nil, skipDirFunc(-1, nil, &ids), nil), ShouldBeNil)
+			So(ids, ShouldResemble, []int64{0, 12, 13, 24, 37})
+			it := dir.IterDFS(nil)
+			for it.Next() {
+			}
+			So(it.Err(), ShouldBeNil)
+			So(dir.ConcurrentWalk(nil, 4, nil, nil), ShouldBeNil)
+
+			//加载之后不再是被跳过的状态
+			files, folders, _ := getSubFilesMock(nil, 1, 12)
+			So(dir12.FillDirNoRecurse(nil, files, folders), ShouldBeNil)
+			So(dir12.IsSkipped(), ShouldBeFalse)
+		})
+
+		Convey("TestSkip DFSLoad SkipAll", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			var preIds, postIds []int64
+			totalSize, totalCount, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock,
+				skipDirFunc(23, SkipAll, &preIds), skipDirFunc(-1, nil, &postIds))
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 13)
+			So(totalSize, ShouldEqual, 8)
+			So(preIds, ShouldResemble, []int64{0, 12, 22, 33, 23})
+			So(postIds, ShouldResemble, []int64{33, 22})
+			So(dir.GetSubDirs()[0].GetSubDirs()[1].IsLoaded(), ShouldBeFalse)
+			So(dir.GetSubDirs()[1].IsLoaded(), ShouldBeFalse)
+			So(dir.GetSubDirs()[1].IsTruncated(), ShouldBeFalse)
+		})
+
+		Convey("TestSkip walk after SkipAll load", func() {
+			loaders := map[string]func(dir *Dir, preorderFunc DirFunc) (int64, int64, error){
+				"dfs": func(dir *Dir, preorderFunc DirFunc) (int64, int64, error) {
+					return dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, preorderFunc, nil)
+				},
+				"bfs": func(dir *Dir, preorderFunc DirFunc) (int64, int64, error) {
+					return dir.BFSLoad(nil, -1, -1, -1, getSubFilesMock, preorderFunc, nil)
+				},
+				"concurrent": func(dir *Dir, preorderFunc DirFunc) (int64, int64, error) {
+					return dir.ConcurrentLoad(nil, 4, -1, -1, -1, getSubFilesMock, preorderFunc, nil)
+				},
+			}
+			for _, name := range []string{"dfs", "bfs", "concurrent"} {
+				load := loaders[name]
+				Convey("TestSkip walk after SkipAll load "+name, func() {
+					buildTreeForTest()
+					dir := newNewVirtualDirForTest()
+					var preIds []int64
+					totalSize, totalCount, err := load(dir, skipDirFunc(22, SkipAll, &preIds))
+					So(err, ShouldBeNil)
+					dir22 := dir.GetSubDirs()[0].GetSubDirs()[0]
+					So(dir22.IsSkipped(), ShouldBeTrue)
+					So(dir22.IsTruncated(), ShouldBeFalse)
+
+					So(dir.DfsWithFunc(nil, nil, nil), ShouldBeNil)
+					So(dir.BfsWithFunc(nil, nil), ShouldBeNil)
+					So(dir.ConcurrentWalk(nil, 4, nil, nil), ShouldBeNil)
+					it := dir.IterDFS(nil)
+					for it.Next() {
+					}
+					So(it.Err(), ShouldBeNil)
+					size, count, err := dir.GetTotalSizeAndCount(nil)
+					So(err, ShouldBeNil)
+					So(count, ShouldEqual, totalCount)
+					So(size, ShouldEqual, totalSize)
+					So(len(dir.GetAllFoldersAndFiles(nil)), ShouldEqual, totalCount)
+				})
+			}
+		})
+
+		Convey("TestSkip DFSLoad postorder SkipAll", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			var postIds []int64
+			_, totalCount, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, skipDirFunc(12, SkipAll, &postIds))
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 16)
+			So(postIds, ShouldResemble, []int64{33, 22, 35, 36, 23, 12})
+		})
+
+		Convey("TestSkip partial load keeps skipped dir untruncated", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			var preIds []int64
+			report := &LoadReport{}
+			_, totalCount, err := dir.DFSLoad(nil, -1, 14, -1, getSubFilesMock,
+				skipDirFunc(23, SkipDir, &preIds), nil, WithPartialResult(report))
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 15)
+			So(len(report.Hits), ShouldEqual, 1)
+			So(report.Hits[0].FolderId, ShouldEqual, 24)
+
+			dir23 := dir.GetSubDirs()[0].GetSubDirs()[1]
+			So(dir23.IsLoaded(), ShouldBeFalse)
+			So(dir23.IsTruncated(), ShouldBeFalse)
+			dir37 := dir.GetSubDirs()[1].GetSubDirs()[0].GetSubDirs()[0]
+			So(dir37.IsLoaded(), ShouldBeFalse)
+			So(dir37.IsTruncated(), ShouldBeTrue)
+		})
+
+		Convey("TestSkip BFSLoad", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			var preIds, postIds []int64
+			totalSize, totalCount, err := dir.BFSLoad(nil, -1, -1, -1, getSubFilesMock,
+				skipDirFunc(22, SkipDir, &preIds), skipDirFunc(-1, nil, &postIds))
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 14)
+			So(totalSize, ShouldEqual, 6)
+			So(postIds, ShouldResemble, []int64{35, 36, 37, 23, 24, 12, 13, 0})
+
+			buildTreeForTest()
+			dir = newNewVirtualDirForTest()
+			preIds = nil
+			_, totalCount, err = dir.BFSLoad(nil, -1, -1, -1, getSubFilesMock, skipDirFunc(13, SkipAll, &preIds), nil)
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 8)
+			So(preIds, ShouldResemble, []int64{0, 12, 13})
+		})
+
+		Convey("TestSkip ConcurrentLoad", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			var preIds, postIds []int64
+			totalSize, totalCount, err := dir.ConcurrentLoad(nil, 4, -1, -1, -1, getSubFilesMock,
+				skipDirFunc(22, SkipDir, &preIds), skipDirFunc(-1, nil, &postIds))
+			So(err, ShouldBeNil)
+			So(totalCount, ShouldEqual, 14)
+			So(totalSize, ShouldEqual, 6)
+			So(postIds, ShouldHaveLength, 8)
+			So(postIds[len(postIds)-1], ShouldEqual, 0)
+
+			buildTreeForTest()
+			dir = newNewVirtualDirForTest()
+			preIds = nil
+			_, _, err = dir.ConcurrentLoad(nil, 4, -1, -1, -1, getSubFilesMock, skipDirFunc(13, SkipAll, &preIds), nil)
+			So(err, ShouldBeNil)
+			So(dir.GetSubDirs()[1].IsLoaded(), ShouldBeFalse)
+		})
+	})
+}
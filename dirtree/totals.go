@@ -0,0 +1,146 @@
+package dirtree
+
+import (
+	"sync/atomic"
+)
+
+/*
+	dirTotals
+	子树的累计统计,不包括dir本身,只统计已经加载的部分。
+	FillDirNoRecurse会把新增的统计逐级累加到所有祖先节点;
+	各种加载方法为了避免每填充一个dir都向上遍历一次,在加载结束时(包括出错和部分加载)自底向上统一计算,
+	所以加载过程中(比如在postorderFunc里面)读到的累计统计还不完整。
+	unloaded记录子树(包括dir本身)中还没加载的dir数量,加载方法填充的dir在统一计算之前保持不为0,
+	所以unloaded为0说明整个子树都已经加载,并且累计统计是最新的。
+	都用atomic读写,避免并发读取时的数据竞争。
+*/
+type dirTotals struct {
+	size        int64 //所有子孙文件的大小之和
+	files       int64 //所有子孙文件的数量
+	folders     int64 //所有子孙文件夹的数量
+	latestMtime int64 //所有子孙文件(夹)中最大的Mtime
+	unloaded    int64 //子树中没有加载的dir数量,包括dir本身
+}
+
+func (t *dirTotals) load() dirTotals {
+	return dirTotals{
+		size:        atomic.LoadInt64(&t.size),
+		files:       atomic.LoadInt64(&t.files),
+		folders:     atomic.LoadInt64(&t.folders),
+		latestMtime: atomic.LoadInt64(&t.latestMtime),
+		unloaded:    atomic.LoadInt64(&t.unloaded),
+	}
+}
+
+func (t *dirTotals) store(v dirTotals) {
+	atomic.StoreInt64(&t.size, v.size)
+	atomic.StoreInt64(&t.files, v.files)
+	atomic.StoreInt64(&t.folders, v.folders)
+	atomic.StoreInt64(&t.latestMtime, v.latestMtime)
+	atomic.StoreInt64(&t.unloaded, v.unloaded)
+}
+
+//merge 累加另一份统计,latestMtime取最大值
+func (t *dirTotals) merge(v dirTotals) {
+	t.size += v.size
+	t.files += v.files
+	t.folders += v.folders
+	t.unloaded += v.unloaded
+	if v.latestMtime > t.latestMtime {
+		t.latestMtime = v.latestMtime
+	}
+}
+
+//ownTotals 只统计当前dir直接的子文件(夹)
+func (d *Dir) ownTotals() dirTotals {
+	var t dirTotals
+	if !d.loaded {
+		return t
+	}
+	t.files = int64(len(d.subFiles))
+	t.folders = int64(len(d.subDirs))
+	for _, file := range d.subFiles {
+		t.size += file.Size
+		if file.Mtime > t.latestMtime {
+			t.latestMtime = file.Mtime
+		}
+	}
+	for _, subDir := range d.subDirs {
+		if subDir.originInfo.Mtime > t.latestMtime {
+			t.latestMtime = subDir.originInfo.Mtime
+		}
+	}
+	return t
+}
+
+//addTotals 把新增的统计累加到d以及d的所有祖先节点上
+func (d *Dir) addTotals(delta dirTotals) {
+	for dir := d; dir != nil; dir = dir.parent {
+		totals := dir.totals
+		atomic.AddInt64(&totals.size, delta.size)
+		atomic.AddInt64(&totals.files, delta.files)
+		atomic.AddInt64(&totals.folders, delta.folders)
+		atomic.AddInt64(&totals.unloaded, delta.unloaded)
+		for {
+			old := atomic.LoadInt64(&totals.latestMtime)
+			if delta.latestMtime <= old || atomic.CompareAndSwapInt64(&totals.latestMtime, old, delta.latestMtime) {
+				break
+			}
+		}
+	}
+}
+
+//recomputeTotals 自底向上重新计算d的整个子树的累计统计,再把d的变化量累加到所有祖先节点上
+func (d *Dir) recomputeTotals() {
+	old := d.totals.load()
+	_ = d.dfsStack(func(dir *Dir) error {
+		if !dir.loaded {
+			dir.totals.store(dirTotals{unloaded: 1})
+			return errSkipSubtree
+		}
+		return nil
+	}, func(dir *Dir) error {
+		t := dir.ownTotals()
+		for _, subDir := range dir.subDirs {
+			t.merge(subDir.totals.load())
+		}
+		dir.totals.store(t)
+		return nil
+	})
+	if d.parent == nil {
+		return
+	}
+	curr := d.totals.load()
+	d.parent.addTotals(dirTotals{
+		size:        curr.size - old.size,
+		files:       curr.files - old.files,
+		folders:     curr.folders - old.folders,
+		latestMtime: curr.latestMtime,
+		unloaded:    curr.unloaded - old.unloaded,
+	})
+}
+
+//GetTotalSize 整个子树(已加载部分)的文件大小之和,O(1)
+func (d *Dir) GetTotalSize() int64 {
+	return atomic.LoadInt64(&d.totals.size)
+}
+
+//GetTotalFileCount 整个子树(已加载部分)的纯文件数量
+func (d *Dir) GetTotalFileCount() int64 {
+	return atomic.LoadInt64(&d.totals.files)
+}
+
+//GetTotalFolderCount 整个子树(已加载部分)的文件夹数量,不包括当前dir本身
+func (d *Dir) GetTotalFolderCount() int64 {
+	return atomic.LoadInt64(&d.totals.folders)
+}
+
+//fullyLoaded 整个子树都已经加载并且累计统计是最新的
+func (d *Dir) fullyLoaded() bool {
+	return d.loaded && atomic.LoadInt64(&d.totals.unloaded) == 0
+}
+
+//GetLatestMtime 整个子树(已加载部分)所有文件(夹)中最大的Mtime,不包括当前dir本身,没有子孙时返回0
+func (d *Dir) GetLatestMtime() int64 {
+	return atomic.LoadInt64(&d.totals.latestMtime)
+}
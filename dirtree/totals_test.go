@@ -0,0 +1,176 @@
+package dirtree
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+//setFakeMtimeForTest 修改模拟数据里面文件的Mtime
+func setFakeMtimeForTest(mtimes map[int64]int64) {
+	for _, sons := range fakeParentAndSons {
+		for _, son := range sons {
+			if mtime, ok := mtimes[son.Id]; ok {
+				son.Mtime = mtime
+			}
+		}
+	}
+}
+
+//walkSizeAndCount 遍历子树统计已加载部分的大小和数量,和GetTotalSizeAndCount的结果对比
+func walkSizeAndCount(d *Dir) (totalSize, totalCount int64) {
+	if !d.IsVirtualDir() {
+		totalCount++
+	}
+	_ = d.DfsWithFunc(nil, func(ctx context.Context, dir *Dir) error {
+		if dir.IsLoaded() {
+			totalSize += dir.GetSize()
+			totalCount += dir.GetCount()
+		}
+		return nil
+	}, nil)
+	return
+}
+
+func TestTotals(t *testing.T) {
+	Convey("TestTotals", t, func() {
+		Convey("TestTotals DFSLoad", func() {
+			buildTreeForTest()
+			setFakeMtimeForTest(map[int64]int64{30: 50, 41: 100, 36: 70})
+			dir := newNewVirtualDirForTest()
+			_, _, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil)
+			So(err, ShouldBeNil)
+
+			So(dir.GetTotalSize(), ShouldEqual, 10)
+			So(dir.GetTotalFileCount(), ShouldEqual, 10)
+			So(dir.GetTotalFolderCount(), ShouldEqual, 9)
+			So(dir.GetLatestMtime(), ShouldEqual, 100)
+
+			dir12 := dir.GetSubDirs()[0]
+			So(dir12.GetTotalSize(), ShouldEqual, 7)
+			So(dir12.GetTotalFileCount(), ShouldEqual, 7)
+			So(dir12.GetTotalFolderCount(), ShouldEqual, 5)
+			So(dir12.GetSubDirs()[1].GetLatestMtime(), ShouldEqual, 70)
+			So(dir.GetSubDirs()[1].GetLatestMtime(), ShouldEqual, 0)
+
+			err = dir.DfsWithFunc(nil, func(ctx context.Context, subDir *Dir) error {
+				totalSize, totalCount, err := subDir.GetTotalSizeAndCount(ctx)
+				So(err, ShouldBeNil)
+				if !subDir.IsVirtualDir() {
+					totalCount-- //GetTotalSizeAndCount算上了根节点
+				}
+				So(subDir.GetTotalSize(), ShouldEqual, totalSize)
+				So(subDir.GetTotalFileCount()+subDir.GetTotalFolderCount(), ShouldEqual, totalCount)
+				return nil
+			}, nil)
+			So(err, ShouldBeNil)
+		})
+
+		Convey("TestTotals FillDirNoRecurse later", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			var preIds []int64
+			_, _, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, skipDirFunc(22, SkipDir, &preIds), nil)
+			So(err, ShouldBeNil)
+			dir12 := dir.GetSubDirs()[0]
+			So(dir12.GetTotalSize(), ShouldEqual, 3)
+			So(dir.GetTotalSize(), ShouldEqual, 6)
+			So(dir.GetTotalFolderCount(), ShouldEqual, 8)
+
+			dir22 := dir12.GetSubDirs()[0]
+			files, folders, err := getSubFilesMock(nil, 1, 22)
+			So(err, ShouldBeNil)
+			So(dir22.FillDirNoRecurse(nil, files, folders), ShouldBeNil)
+			So(dir22.GetTotalSize(), ShouldEqual, 3)
+			So(dir12.GetTotalSize(), ShouldEqual, 6)
+			So(dir.GetTotalSize(), ShouldEqual, 9)
+			So(dir.GetTotalFileCount(), ShouldEqual, 9)
+			So(dir.GetTotalFolderCount(), ShouldEqual, 9)
+
+			So(dir22.FillDirNoRecurse(nil, files, folders), ShouldNotBeNil)
+			So(dir.GetTotalSize(), ShouldEqual, 9)
+		})
+
+		Convey("TestTotals load subtree later", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			var preIds []int64
+			_, _, err := dir.BFSLoad(nil, -1, -1, -1, getSubFilesMock, skipDirFunc(22, SkipDir, &preIds), nil)
+			So(err, ShouldBeNil)
+			So(dir.GetTotalSize(), ShouldEqual, 6)
+
+			dir22 := dir.GetSubDirs()[0].GetSubDirs()[0]
+			_, _, err = dir22.DFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil)
+			So(err, ShouldBeNil)
+			So(dir22.GetTotalSize(), ShouldEqual, 4)
+			So(dir.GetSubDirs()[0].GetTotalSize(), ShouldEqual, 7)
+			So(dir.GetTotalSize(), ShouldEqual, 10)
+			So(dir.GetTotalFileCount(), ShouldEqual, 10)
+			So(dir.GetTotalFolderCount(), ShouldEqual, 9)
+		})
+
+		Convey("TestTotals ConcurrentLoad", func() {
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			_, _, err := dir.ConcurrentLoad(nil, 4, -1, -1, -1, getSubFilesMock, nil, nil)
+			So(err, ShouldBeNil)
+			So(dir.GetTotalSize(), ShouldEqual, 10)
+			So(dir.GetTotalFileCount(), ShouldEqual, 10)
+			So(dir.GetTotalFolderCount(), ShouldEqual, 9)
+		})
+
+		Convey("TestTotals GetTotalSizeAndCount", func() {
+			canceled, cancel := context.WithCancel(context.Background())
+			cancel()
+			buildTreeForTest()
+			dir := newNewVirtualDirForTest()
+			var preIds []int64
+			_, _, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, skipDirFunc(22, SkipDir, &preIds),
+				func(ctx context.Context, subDir *Dir) error {
+					//加载过程中累计统计还没计算,需要遍历
+					totalSize, totalCount, err := subDir.GetTotalSizeAndCount(ctx)
+					So(err, ShouldBeNil)
+					wantSize, wantCount := walkSizeAndCount(subDir)
+					So(totalSize, ShouldEqual, wantSize)
+					So(totalCount, ShouldEqual, wantCount)
+					return nil
+				})
+			So(err, ShouldBeNil)
+			So(dir.fullyLoaded(), ShouldBeFalse)
+			_, _, err = dir.GetTotalSizeAndCount(canceled)
+			So(err, ShouldEqual, context.Canceled) //没有完全加载,遍历子树
+			totalSize, totalCount, err := dir.GetTotalSizeAndCount(nil)
+			So(err, ShouldBeNil)
+			So(totalSize, ShouldEqual, 6)
+			So(totalCount, ShouldEqual, 14)
+
+			dir22 := dir.GetSubDirs()[0].GetSubDirs()[0]
+			files, folders, err := getSubFilesMock(nil, 1, 22)
+			So(err, ShouldBeNil)
+			So(dir22.FillDirNoRecurse(nil, files, folders), ShouldBeNil)
+			So(dir.fullyLoaded(), ShouldBeFalse) //22的子目录还没加载
+
+			for _, subDir := range dir22.GetSubDirs() {
+				_, _, err = subDir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil)
+				So(err, ShouldBeNil)
+			}
+			So(dir.fullyLoaded(), ShouldBeTrue)
+			totalSize, totalCount, err = dir.GetTotalSizeAndCount(canceled) //完全加载,不再遍历
+			So(err, ShouldBeNil)
+			So(totalSize, ShouldEqual, 10)
+			So(totalCount, ShouldEqual, 19)
+
+			So(dir.AddNode(nil, 22, &File{Id: 100, VolumeId: 1, Type: typeFolder}), ShouldBeNil)
+			So(dir.addNode(nil, 22, &File{Id: 101, VolumeId: 1, Type: typeFolder}, false), ShouldBeNil)
+			So(dir.fullyLoaded(), ShouldBeFalse)
+			_, err = dir.RemoveNode(nil, 101)
+			So(err, ShouldBeNil)
+			So(dir.fullyLoaded(), ShouldBeTrue)
+			totalSize, totalCount, err = dir.GetTotalSizeAndCount(canceled)
+			So(err, ShouldBeNil)
+			So(totalSize, ShouldEqual, 10)
+			So(totalCount, ShouldEqual, 20)
+		})
+	})
+}
@@ -0,0 +1,124 @@
+package dirtree
+
+import (
+	"context"
+	"fmt"
+)
+
+//ViolationKind 目录树不变式被破坏的类型
+type ViolationKind int
+
+const (
+	ViolationParentId     ViolationKind = iota + 1 //子节点的ParentId不等于所在dir的id
+	ViolationVolumeId                              //子节点的VolumeId和根节点不一致
+	ViolationDepth                                 //子目录的depth不等于父目录的depth+1
+	ViolationCount                                 //已加载dir的count不等于len(subDirs)+len(subFiles)
+	ViolationFolderAsFile                          //文件夹出现在subFiles里
+)
+
+var violationKind2Str = map[ViolationKind]string{
+	ViolationParentId:     "parent id",
+	ViolationVolumeId:     "volume id",
+	ViolationDepth:        "depth",
+	ViolationCount:        "count",
+	ViolationFolderAsFile: "folder as file",
+}
+
+func (k ViolationKind) String() string {
+	if s, ok := violationKind2Str[k]; ok {
+		return s
+	}
+	return fmt.Sprintf("ViolationKind(%d)", int(k))
+}
+
+//Violation 一处不变式被破坏的地方
+type Violation struct {
+	Kind    ViolationKind
+	Id      int64  //出问题的节点id,ViolationCount时是dir本身的id
+	DirId   int64  //节点所在dir的id
+	Message string //可读的描述
+}
+
+func (v *Violation) String() string {
+	return fmt.Sprintf("%v id=%d,dirId=%d: %s", v.Kind, v.Id, v.DirId, v.Message)
+}
+
+/*
+	Validate
+	检查以当前dir为根的已加载部分是否满足目录树的不变式,返回所有发现的问题(按DFS顺序),没有问题时返回nil:
+	1.子文件(夹)的ParentId等于所在dir的id;
+	2.子文件(夹)的VolumeId等于当前dir的VolumeId;
+	3.子目录的depth等于父目录的depth+1;
+	4.已加载dir的count等于len(subDirs)+len(subFiles);
+	5.subFiles里面没有文件夹。
+	没有加载的dir只检查它自己(作为父目录的子目录),不检查它的子节点。只有ctx取消时返回错误。
+*/
+func (d *Dir) Validate(ctx context.Context) ([]*Violation, error) {
+	var violations []*Violation
+	add := func(kind ViolationKind, id int64, dir *Dir, format string, args ...any) {
+		violations = append(violations, &Violation{
+			Kind:    kind,
+			Id:      id,
+			DirId:   dir.originInfo.Id,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+	volumeId := d.originInfo.VolumeId
+	err := d.dfsStack(func(dir *Dir) error {
+		if err := ctxErr(ctx); err != nil {
+			return err
+		}
+		if !dir.loaded {
+			return errSkipSubtree
+		}
+		dirId := dir.originInfo.Id
+		if count := int64(len(dir.subDirs) + len(dir.subFiles)); dir.count != count {
+			add(ViolationCount, dirId, dir, "count is %d, but has %d sub folders and %d sub files",
+				dir.count, len(dir.subDirs), len(dir.subFiles))
+		}
+		for _, subDir := range dir.subDirs {
+			info := subDir.originInfo
+			if info.ParentId != dirId {
+				add(ViolationParentId, info.Id, dir, "folder parent id is %d, want %d", info.ParentId, dirId)
+			}
+			if info.VolumeId != volumeId {
+				add(ViolationVolumeId, info.Id, dir, "folder volume id is %d, want %d", info.VolumeId, volumeId)
+			}
+			if subDir.depth != dir.depth+1 {
+				add(ViolationDepth, info.Id, dir, "folder depth is %d, want %d", subDir.depth, dir.depth+1)
+			}
+		}
+		for _, file := range dir.subFiles {
+			if file.IsFolder() {
+				add(ViolationFolderAsFile, file.Id, dir, "folder listed in sub files")
+			}
+			if file.ParentId != dirId {
+				add(ViolationParentId, file.Id, dir, "file parent id is %d, want %d", file.ParentId, dirId)
+			}
+			if file.VolumeId != volumeId {
+				add(ViolationVolumeId, file.Id, dir, "file volume id is %d, want %d", file.VolumeId, volumeId)
+			}
+		}
+		return nil
+	}, nil)
+	return violations, err
+}
+
+//WithValidation 加载成功结束后用Validate检查整棵树,发现问题时返回*ValidationError,可以用errors.Is判断ErrInvalidTree
+func WithValidation() LoadOption {
+	return func(opts *loadOptions) {
+		opts.validate = true
+	}
+}
+
+//validateLoaded 加载结束后的检查
+func (d *Dir) validateLoaded(ctx context.Context) error {
+	violations, err := d.Validate(ctx)
+	if err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return &ValidationError{Violations: violations}
+	}
+	return nil
+}
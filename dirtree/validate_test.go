@@ -0,0 +1,96 @@
+package dirtree
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func violationStrings(violations []*Violation) []string {
+	var strs []string
+	for _, v := range violations {
+		strs = append(strs, v.String())
+	}
+	return strs
+}
+
+func TestValidate(t *testing.T) {
+	Convey("TestValidate", t, func() {
+		buildTreeForTest()
+
+		Convey("TestValidate valid tree", func() {
+			dir := newNewVirtualDirForTest()
+			_, _, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil, WithValidation())
+			So(err, ShouldBeNil)
+			violations, err := dir.Validate(nil)
+			So(err, ShouldBeNil)
+			So(violations, ShouldBeEmpty)
+
+			bfsDir := newNewVirtualDirForTest()
+			_, _, err = bfsDir.BFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil, WithValidation())
+			So(err, ShouldBeNil)
+
+			So(dir.AddNode(nil, 22, &File{Id: 50, VolumeId: 1, Type: typeFolder}), ShouldBeNil)
+			So(dir.MoveNode(nil, 22, 37), ShouldBeNil)
+			violations, err = dir.Validate(nil)
+			So(err, ShouldBeNil)
+			So(violations, ShouldBeEmpty)
+		})
+
+		Convey("TestValidate broken tree", func() {
+			dir := newNewVirtualDirForTest()
+			_, _, err := dir.DFSLoad(nil, -1, -1, -1, getSubFilesMock, nil, nil)
+			So(err, ShouldBeNil)
+			dir12 := dir.GetSubDirs()[0]
+			dir22 := dir12.GetSubDirs()[0]
+			dir22.depth = 5
+			dir12.count = 1
+			dir22.GetSubFiles()[0].ParentId = 99
+			dir.GetSubFiles()[1].VolumeId = 2
+			dir22.GetSubDirs()[0].subFiles = append(dir22.GetSubDirs()[0].subFiles, &File{Id: 60, ParentId: 33, VolumeId: 1, Type: typeFolder})
+			dir22.GetSubDirs()[0].count++
+
+			violations, err := dir.Validate(nil)
+			So(err, ShouldBeNil)
+			So(violationStrings(violations), ShouldResemble, []string{
+				"volume id id=11,dirId=0: file volume id is 2, want 1",
+				"count id=12,dirId=12: count is 1, but has 2 sub folders and 2 sub files",
+				"depth id=22,dirId=12: folder depth is 5, want 2",
+				"depth id=33,dirId=22: folder depth is 3, want 6",
+				"parent id id=30,dirId=22: file parent id is 99, want 22",
+				"folder as file id=60,dirId=33: folder listed in sub files",
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err = dir.Validate(ctx)
+			So(err, ShouldEqual, context.Canceled)
+		})
+
+		Convey("TestValidate post-load check", func() {
+			badRetrieve := func(ctx context.Context, volumeId, parentId int64) (files, folders []*File, err error) {
+				files, folders, err = getSubFilesMock(ctx, volumeId, parentId)
+				if parentId == 23 {
+					files = append(files, &File{Id: 70, ParentId: 24, VolumeId: 2, Type: typeFile})
+				}
+				return
+			}
+			dir := newNewVirtualDirForTest()
+			_, _, err := dir.DFSLoad(nil, -1, -1, -1, badRetrieve, nil, nil, WithValidation())
+			So(errors.Is(err, ErrInvalidTree), ShouldBeTrue)
+			var validationErr *ValidationError
+			So(errors.As(err, &validationErr), ShouldBeTrue)
+			So(violationStrings(validationErr.Violations), ShouldResemble, []string{
+				"parent id id=70,dirId=23: file parent id is 24, want 23",
+				"volume id id=70,dirId=23: file volume id is 2, want 1",
+			})
+			So(err.Error(), ShouldEqual, "dirtree invalid tree: parent id id=70,dirId=23: file parent id is 24, want 23 (and 1 more violations)")
+
+			dir = newNewVirtualDirForTest()
+			_, _, err = dir.DFSLoad(nil, -1, -1, -1, badRetrieve, nil, nil)
+			So(err, ShouldBeNil)
+		})
+	})
+}